@@ -27,6 +27,8 @@ type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
 	Value Expression
+	// written export let, the binding is visible to files importing this one
+	Exported bool
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -49,6 +51,15 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -65,7 +76,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return `"` + sl.Value + `"` }
 
 type ArrayLiteral struct {
 	Token    token.Token
@@ -111,16 +122,23 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// keys of Pairs in source order, along with the spread hashes, which
+	// have no entry in Pairs
+	Keys []Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
-func (hl *HashLiteral) TokenLiteral() string { return hl.TokenLiteral() }
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":", value.String())
+	for _, key := range hl.Keys {
+		if spread, ok := key.(*SpreadExpression); ok {
+			pairs = append(pairs, spread.String())
+			continue
+		}
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -141,15 +159,16 @@ func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") { ")
 	out.WriteString(ie.Consequence.String())
+	out.WriteString(" }")
 
 	if ie.Alternative != nil {
-		out.WriteString("else")
-		out.WriteString(" ")
+		out.WriteString(" else { ")
 		out.WriteString(ie.Alternative.String())
+		out.WriteString(" }")
 	}
 
 	return out.String()
@@ -158,23 +177,27 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	EndToken   token.Token // the closing brace
 }
 
 func (bs *BlockStatement) expressionNode()      {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+// statements are separated by "; " so a printed block parses back the same
 func (bs *BlockStatement) String() string {
-	var out bytes.Buffer
+	stmts := []string{}
 	for _, st := range bs.Statements {
-		out.WriteString(st.String())
+		stmts = append(stmts, st.String())
 	}
 
-	return out.String()
+	return strings.Join(stmts, "; ")
 }
 
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   map[*Identifier]Expression // default values of the optional parameters
+	Variadic   bool                       // the last parameter collects the remaining arguments
 	Body       *BlockStatement
 }
 
@@ -183,19 +206,34 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(")")
+	out.WriteString(ParameterList(fl.Parameters, fl.Defaults, fl.Variadic))
+	out.WriteString(") { ")
 	out.WriteString(fl.Body.String())
+	out.WriteString(" }")
 	return out.String()
 }
 
+// parameters as written in source, a = default for optional ones and ...rest
+// for the last one of a variadic function
+func ParameterList(params []*Identifier, defaults map[*Identifier]Expression, variadic bool) string {
+	list := []string{}
+	for i, p := range params {
+		switch {
+		case variadic && i == len(params)-1:
+			list = append(list, "..."+p.String())
+		case defaults[p] != nil:
+			list = append(list, p.String()+" = "+defaults[p].String())
+		default:
+			list = append(list, p.String())
+		}
+	}
+
+	return strings.Join(list, ", ")
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
@@ -219,6 +257,28 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// ...Value spreads an array into the arguments of a call or the elements
+// of an array, or a hash into the pairs of a hash
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// name: Value passes an argument to the parameter called name
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -265,6 +325,31 @@ type ReturnStatement struct {
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+// import "path" as Name binds the module at path to Name
+type ImportStatement struct {
+	Token token.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " " + is.Path.String() + " as " + is.Name.String()
+}
+
+// import(Path) evaluates to the module at the path Path evaluates to
+type ImportExpression struct {
+	Token token.Token
+	Path  Expression
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + "(" + ie.Path.String() + ")"
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -289,18 +374,21 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+// statements are separated like those of a block so the program parses back
 func (p *Program) String() string {
-	var out bytes.Buffer
-
+	stmts := []string{}
 	for _, s := range p.Statements {
-		out.WriteString(s.String())
+		stmts = append(stmts, s.String())
 	}
 
-	return out.String()
+	return strings.Join(stmts, "; ")
 }
 
 func (l *LetStatement) String() string {
 	var out bytes.Buffer
+	if l.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString(l.TokenLiteral() + " ")
 	out.WriteString(l.Name.String())
 	out.WriteString(" = ")
@@ -314,10 +402,10 @@ func (l *LetStatement) String() string {
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
+	out.WriteString(rs.TokenLiteral() + " ")
 	if rs.ReturnValue != nil {
 		out.WriteString(rs.ReturnValue.String())
 	}
-	out.WriteString(";")
 	return out.String()
 }
 
@@ -0,0 +1,58 @@
+package ast
+
+import "interpreter/token"
+
+// Start returns the first token of node in the source, which locates it
+func Start(node Node) token.Token {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return Start(n.Statements[0])
+		}
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *ExpressionStatement:
+		return n.Token
+	case *ImportStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *FloatLiteral:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *HashLiteral:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *SpreadExpression:
+		return n.Token
+	case *ImportExpression:
+		return n.Token
+	case *NamedArgument:
+		return n.Token
+	// the token of these is the operator, their source starts on the left
+	case *InfixExpression:
+		return Start(n.Left)
+	case *CallExpression:
+		return Start(n.Function)
+	case *IndexExpression:
+		return Start(n.Left)
+	}
+
+	return token.Token{}
+}
@@ -0,0 +1,112 @@
+package ast
+
+/**
+ * Inspect visits node and every node below it in source order, f is called
+ * before the children of a node and returning false skips those children
+ */
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+
+	case *LetStatement:
+		Inspect(n.Name, f)
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Inspect(n.ReturnValue, f)
+		}
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Inspect(n.Expression, f)
+		}
+
+	case *ImportStatement:
+		if n.Path != nil {
+			Inspect(n.Path, f)
+		}
+		if n.Name != nil {
+			Inspect(n.Name, f)
+		}
+
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+
+	case *PrefixExpression:
+		inspectExpression(n.Right, f)
+
+	case *InfixExpression:
+		inspectExpression(n.Left, f)
+		inspectExpression(n.Right, f)
+
+	case *IfExpression:
+		inspectExpression(n.Condition, f)
+		if n.Consequence != nil {
+			Inspect(n.Consequence, f)
+		}
+		if n.Alternative != nil {
+			Inspect(n.Alternative, f)
+		}
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Inspect(p, f)
+			if d := n.Defaults[p]; d != nil {
+				inspectExpression(d, f)
+			}
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+
+	case *ImportExpression:
+		inspectExpression(n.Path, f)
+
+	case *SpreadExpression:
+		inspectExpression(n.Value, f)
+
+	// the name is not visited, it refers to a parameter rather than a binding
+	case *NamedArgument:
+		inspectExpression(n.Value, f)
+
+	case *CallExpression:
+		inspectExpression(n.Function, f)
+		for _, a := range n.Arguments {
+			inspectExpression(a, f)
+		}
+
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			inspectExpression(e, f)
+		}
+
+	case *IndexExpression:
+		inspectExpression(n.Left, f)
+		inspectExpression(n.Index, f)
+
+	case *HashLiteral:
+		for _, k := range n.Keys {
+			inspectExpression(k, f)
+			inspectExpression(n.Pairs[k], f)
+		}
+	}
+}
+
+// a failed parse leaves nil expressions behind, which are skipped
+func inspectExpression(e Expression, f func(Node) bool) {
+	if e != nil {
+		Inspect(e, f)
+	}
+}
@@ -0,0 +1,150 @@
+package conformance
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the current behavior")
+
+/**
+ * engines run a parsed program, writing what it prints to out, each one has
+ * to produce the output and result recorded in the golden files, a new
+ * engine such as a bytecode VM only needs to be added here
+ */
+var engines = []struct {
+	name string
+	run  func(program *ast.Program, out io.Writer) object.Object
+}{
+	{"evaluator", func(program *ast.Program, out io.Writer) object.Object {
+		env := object.NewEnvironment()
+		env.SetHost(&object.Host{Out: out})
+		return evaluator.Eval(program, env)
+	}},
+}
+
+/**
+ * TestConformance runs every program in testdata through the lexer, the
+ * parser and each engine and compares what they produce to the .golden file
+ * next to it, go test ./conformance -update rewrites the golden files
+ */
+func TestConformance(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("no programs found in testdata")
+	}
+
+	for _, source := range sources {
+		name := strings.TrimSuffix(filepath.Base(source), ".monkey")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(source, ".monkey") + ".golden"
+			for i, engine := range engines {
+				actual := run(string(input), engine.run)
+
+				// the first engine is the reference the golden files come from
+				if i == 0 && *update {
+					if err := os.WriteFile(golden, []byte(actual), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+
+				expected, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v, run go test ./conformance -update to create it", err)
+				}
+				if actual != string(expected) {
+					t.Errorf("%s differs from %s\n--- got\n%s\n--- want\n%s", engine.name, golden, actual, expected)
+				}
+			}
+		})
+	}
+}
+
+// run input through every stage and describe the results in golden form
+func run(input string, engine func(*ast.Program, io.Writer) object.Object) string {
+	var out strings.Builder
+
+	out.WriteString("-- tokens --\n")
+	out.WriteString(tokens(input))
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if details := p.ErrorDetails(); len(details) != 0 {
+		out.WriteString("-- parse errors --\n")
+		for _, e := range details {
+			fmt.Fprintf(&out, "%d:%d: %s\n", e.Line, e.Column, e.Message)
+		}
+		return out.String()
+	}
+
+	out.WriteString("-- ast --\n")
+	for _, stmt := range program.Statements {
+		out.WriteString(stmt.String() + "\n")
+	}
+
+	var printed bytes.Buffer
+	result := engine(program, &printed)
+
+	out.WriteString("-- output --\n")
+	out.Write(printed.Bytes())
+
+	out.WriteString("-- result --\n")
+	if result == nil {
+		out.WriteString("nil\n")
+	} else {
+		out.WriteString(result.Inspect() + "\n")
+	}
+
+	return out.String()
+}
+
+// tokens of input, one source line per line, tokens standing for a single
+// spelling are written as it, the others as TYPE(literal)
+func tokens(input string) string {
+	var out strings.Builder
+	l := lexer.New(input)
+
+	line := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Line != line {
+			if line != 0 {
+				out.WriteString("\n")
+			}
+			line = tok.Line
+			fmt.Fprintf(&out, "%d:", line)
+		}
+
+		switch tok.Type {
+		case token.IDENT, token.INT, token.FLOAT, token.STRING, token.ILLEGAL:
+			fmt.Fprintf(&out, " %s(%s)", tok.Type, tok.Literal)
+		default:
+			fmt.Fprintf(&out, " %s", tok.Literal)
+		}
+	}
+	if line != 0 {
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
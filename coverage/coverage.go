@@ -0,0 +1,178 @@
+package coverage
+
+import (
+	"interpreter/ast"
+	"interpreter/object"
+	"sort"
+	"strings"
+)
+
+// File is the coverage of one script
+type File struct {
+	Path  string
+	lines []string
+
+	statements []*statement
+	branches   []*branch
+}
+
+type statement struct {
+	line  int
+	count int
+}
+
+// branch counts how often an if expression took each way, its alternative
+// counts the runs skipping the consequence even when there is no else
+type branch struct {
+	line        int
+	consequence int
+	alternative int
+}
+
+func (b *branch) evaluated() bool {
+	return b.consequence+b.alternative > 0
+}
+
+/**
+ * Coverage is an object.BranchTracer counting how often each statement of
+ * the added files runs and which way each of their if expressions goes
+ */
+type Coverage struct {
+	files      []*File
+	statements map[ast.Statement]*statement
+	branches   map[*ast.IfExpression]*branch
+}
+
+func New() *Coverage {
+	return &Coverage{
+		statements: make(map[ast.Statement]*statement),
+		branches:   make(map[*ast.IfExpression]*branch),
+	}
+}
+
+// Add registers the statements and branches of a parsed script, only added
+// files are covered
+func (c *Coverage) Add(path, source string, program *ast.Program) *File {
+	f := &File{Path: path, lines: strings.Split(strings.TrimSuffix(source, "\n"), "\n")}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case ast.Statement:
+			s := &statement{line: ast.Start(n).Line}
+			c.statements[n] = s
+			f.statements = append(f.statements, s)
+		case *ast.IfExpression:
+			b := &branch{line: n.Token.Line}
+			c.branches[n] = b
+			f.branches = append(f.branches, b)
+		}
+		return true
+	})
+
+	c.files = append(c.files, f)
+	return f
+}
+
+// Attach makes env and every evaluation in it report to the coverage
+func (c *Coverage) Attach(env *object.Environment) {
+	env.SetTracer(c)
+}
+
+func (c *Coverage) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if s, ok := c.statements[stmt]; ok {
+		s.count++
+	}
+	return nil
+}
+
+func (c *Coverage) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {}
+
+func (c *Coverage) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object) {}
+
+func (c *Coverage) Branch(ie *ast.IfExpression, consequence bool, env *object.Environment) {
+	b, ok := c.branches[ie]
+	if !ok {
+		return
+	}
+
+	if consequence {
+		b.consequence++
+	} else {
+		b.alternative++
+	}
+}
+
+// Files in the order they were added
+func (c *Coverage) Files() []*File {
+	return c.files
+}
+
+// Line is the coverage of one line holding at least one statement
+type Line struct {
+	Number int
+	// runs of the statement run most often among those starting on the line
+	Count int
+}
+
+// lines with statements in ascending order
+func (f *File) Lines() []Line {
+	counts := make(map[int]int)
+	for _, s := range f.statements {
+		if count, ok := counts[s.line]; !ok || s.count > count {
+			counts[s.line] = s.count
+		}
+	}
+
+	lines := []Line{}
+	for number, count := range counts {
+		lines = append(lines, Line{Number: number, Count: count})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Number < lines[j].Number })
+
+	return lines
+}
+
+// Statements returns how many statements there are and how many of them ran
+func (f *File) Statements() (int, int) {
+	covered := 0
+	for _, s := range f.statements {
+		if s.count > 0 {
+			covered++
+		}
+	}
+
+	return len(f.statements), covered
+}
+
+// Branches returns how many ways the if expressions can go, two each, and
+// how many of them were taken
+func (f *File) Branches() (int, int) {
+	taken := 0
+	for _, b := range f.branches {
+		if b.consequence > 0 {
+			taken++
+		}
+		if b.alternative > 0 {
+			taken++
+		}
+	}
+
+	return 2 * len(f.branches), taken
+}
+
+// source text of line n, counted from 1
+func (f *File) source(n int) string {
+	if n < 1 || n > len(f.lines) {
+		return ""
+	}
+
+	return f.lines[n-1]
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+
+	return 100 * float64(covered) / float64(total)
+}
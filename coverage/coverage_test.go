@@ -0,0 +1,151 @@
+package coverage
+
+import (
+	"bytes"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strings"
+	"testing"
+)
+
+const script = `let abs = fn(x) {
+  if (x < 0) {
+    return -x;
+  }
+  x
+};
+let sign = fn(x) { if (x > 0) { 1 } else { -1 } };
+abs(-3);
+abs(-4);
+sign(2);
+if (false) { 1 }
+`
+
+func cover(t *testing.T) *Coverage {
+	t.Helper()
+
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors %v", p.Errors())
+	}
+
+	c := New()
+	c.Add("abs_test.monkey", script, program)
+
+	env := object.NewEnvironment()
+	c.Attach(env)
+	evaluator.Eval(program, env)
+
+	return c
+}
+
+func TestCounts(t *testing.T) {
+	f := cover(t).Files()[0]
+
+	lines := map[int]int{}
+	for _, line := range f.Lines() {
+		lines[line.Number] = line.Count
+	}
+
+	expected := map[int]int{1: 1, 2: 2, 3: 2, 5: 0, 7: 1, 8: 1, 9: 1, 10: 1, 11: 1}
+	if len(lines) != len(expected) {
+		t.Errorf("lines got %v, but want %v", lines, expected)
+	}
+	for n, count := range expected {
+		if lines[n] != count {
+			t.Errorf("line %d ran %d times, but want %d", n, lines[n], count)
+		}
+	}
+
+	// 6 statements at the top, the if, return and x of abs, the if, 1 and -1
+	// of sign and the 1 of the last if, of which x, -1 and the last 1 never run
+	statements, run := f.Statements()
+	if statements != 13 || run != 10 {
+		t.Errorf("statements got %d of %d, but want 10 of 13", run, statements)
+	}
+
+	branches, taken := f.Branches()
+	if branches != 6 || taken != 3 {
+		t.Errorf("branches got %d of %d, but want 3 of 6", taken, branches)
+	}
+}
+
+func TestText(t *testing.T) {
+	var out bytes.Buffer
+	cover(t).WriteText(&out)
+
+	expected := []string{
+		"abs_test.monkey: 76.9% of statements (10/13), 50.0% of branches (3/6)",
+		"       2     3      return -x;",
+		"       -     4    }",
+		"   #####     5    x",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e+"\n") {
+			t.Errorf("text report is missing %q\n%s", e, out.String())
+		}
+	}
+}
+
+func TestLCOV(t *testing.T) {
+	var out bytes.Buffer
+	cover(t).WriteLCOV(&out)
+
+	expected := `TN:
+SF:abs_test.monkey
+DA:1,1
+DA:2,2
+DA:3,2
+DA:5,0
+DA:7,1
+DA:8,1
+DA:9,1
+DA:10,1
+DA:11,1
+BRDA:2,0,0,2
+BRDA:2,0,1,0
+BRDA:7,1,0,1
+BRDA:7,1,1,0
+BRDA:11,2,0,0
+BRDA:11,2,1,1
+BRF:6
+BRH:3
+LF:9
+LH:8
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("LCOV got\n%s\nbut want\n%s", out.String(), expected)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var out bytes.Buffer
+	cover(t).WriteHTML(&out)
+	html := out.String()
+
+	expected := []string{
+		`<tr class="uncovered"><td class="number">5</td><td class="count">#####</td><td>  x</td></tr>`,
+		`<tr class="covered partial"><td class="number">2</td><td class="count">2</td><td>  if (x &lt; 0) {</td></tr>`,
+		`<tr class=""><td class="number">4</td><td class="count"></td><td>  }</td></tr>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(html, e) {
+			t.Errorf("HTML report is missing %q", e)
+		}
+	}
+}
+
+func TestUnaddedFilesAreIgnored(t *testing.T) {
+	c := New()
+	env := object.NewEnvironment()
+	c.Attach(env)
+	evaluator.Eval(parser.New(lexer.New("if (true) { 1 }")).ParseProgram(), env)
+
+	if len(c.Files()) != 0 {
+		t.Errorf("nothing should be covered, got %v", c.Files())
+	}
+}
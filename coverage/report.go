@@ -0,0 +1,153 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+)
+
+// Summary is a one line account of a file, as printed by monkey test -cover
+func (f *File) Summary() string {
+	statements, run := f.Statements()
+	branches, taken := f.Branches()
+
+	return fmt.Sprintf("%s: %.1f%% of statements (%d/%d), %.1f%% of branches (%d/%d)",
+		f.Path, percent(run, statements), run, statements, percent(taken, branches), taken, branches)
+}
+
+// count column of a line, - for lines without statements and ##### for
+// lines which never ran
+func (f *File) counts() map[int]string {
+	counts := make(map[int]string)
+	for _, line := range f.Lines() {
+		if line.Count == 0 {
+			counts[line.Number] = "#####"
+		} else {
+			counts[line.Number] = strconv.Itoa(line.Count)
+		}
+	}
+
+	return counts
+}
+
+// WriteText writes each file annotated with how often its lines ran
+func (c *Coverage) WriteText(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	for _, f := range c.files {
+		fmt.Fprintln(out, f.Summary())
+
+		counts := f.counts()
+		for n := 1; n <= len(f.lines); n++ {
+			count, ok := counts[n]
+			if !ok {
+				count = "-"
+			}
+			fmt.Fprintf(out, "%8s %5d  %s\n", count, n, f.source(n))
+		}
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
+
+/**
+ * WriteLCOV writes the coverage in the LCOV tracefile format read by genhtml
+ * and most editors, each if expression is a block of two branches, the
+ * consequence and the alternative
+ */
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	for _, f := range c.files {
+		fmt.Fprintln(out, "TN:")
+		fmt.Fprintf(out, "SF:%s\n", f.Path)
+
+		lines := f.Lines()
+		hit := 0
+		for _, line := range lines {
+			fmt.Fprintf(out, "DA:%d,%d\n", line.Number, line.Count)
+			if line.Count > 0 {
+				hit++
+			}
+		}
+
+		for i, b := range f.branches {
+			for way, count := range []int{b.consequence, b.alternative} {
+				taken := "-"
+				if b.evaluated() {
+					taken = strconv.Itoa(count)
+				}
+				fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", b.line, i, way, taken)
+			}
+		}
+
+		branches, taken := f.Branches()
+		fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", branches, taken)
+		fmt.Fprintf(out, "LF:%d\nLH:%d\n", len(lines), hit)
+		fmt.Fprintln(out, "end_of_record")
+	}
+
+	return out.Flush()
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monkey coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 8px; white-space: pre; }
+td.count, td.number { text-align: right; color: #888; }
+tr.covered { background: #dfd; }
+tr.uncovered { background: #fdd; }
+tr.partial td.count { background: #ffc; }
+</style>
+</head>
+<body>
+`
+
+// WriteHTML writes a standalone page showing each file with its lines
+// colored by whether they ran, partly taken branches are highlighted
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	io.WriteString(out, htmlHeader)
+
+	for _, f := range c.files {
+		fmt.Fprintf(out, "<h2>%s</h2>\n<p>%s</p>\n<table>\n", html.EscapeString(f.Path), html.EscapeString(f.Summary()))
+
+		counts := f.counts()
+		partial := make(map[int]bool)
+		for _, b := range f.branches {
+			if b.consequence == 0 || b.alternative == 0 {
+				partial[b.line] = true
+			}
+		}
+
+		for n := 1; n <= len(f.lines); n++ {
+			class := ""
+			count, ok := counts[n]
+			switch {
+			case !ok:
+				count = ""
+			case count == "#####":
+				class = "uncovered"
+			case partial[n]:
+				class = "covered partial"
+			default:
+				class = "covered"
+			}
+
+			fmt.Fprintf(out, "<tr class=%q><td class=\"number\">%d</td><td class=\"count\">%s</td><td>%s</td></tr>\n",
+				class, n, count, html.EscapeString(f.source(n)))
+		}
+		io.WriteString(out, "</table>\n")
+	}
+
+	io.WriteString(out, "</body>\n</html>\n")
+	return out.Flush()
+}
@@ -0,0 +1,586 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/debugger"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/rpc"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// scripts run on a single thread
+const THREAD_ID = 1
+
+/**
+ * Server is one debug session, it launches a script, evaluates it on its own
+ * goroutine under a debugger.Debugger and answers requests while the script
+ * runs or waits in a stop
+ */
+type Server struct {
+	out io.Writer
+	mu  sync.Mutex // guards writes to out and seq
+	seq int
+
+	debugger *debugger.Debugger
+
+	// the launched script
+	path    string
+	program *ast.Program
+	// lines on which a statement starts, the only ones a breakpoint can hit
+	lines       map[int]bool
+	stopOnEntry bool
+	noDebug     bool
+
+	launched   bool
+	configured bool
+	started    bool
+
+	// guards paused and quitting, shared with the evaluating goroutine
+	state    sync.Mutex
+	paused   bool
+	quitting bool
+	resume   chan struct{}
+	done     chan struct{}
+
+	// values the client may expand, a variables reference is an index + 1,
+	// they are only valid until the script resumes
+	handles []any
+}
+
+func NewServer(out io.Writer) *Server {
+	s := &Server{
+		out:    out,
+		resume: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	s.debugger = debugger.New(s.pause)
+
+	return s
+}
+
+/**
+ * Serve reads requests from in and answers them on out until the client
+ * disconnects or closes the stream
+ */
+func Serve(in io.Reader, out io.Writer) error {
+	s := NewServer(out)
+	defer s.stop()
+
+	r := bufio.NewReader(in)
+	for {
+		body, err := rpc.ReadMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !s.handle(body) {
+			return nil
+		}
+	}
+}
+
+/**
+ * accept connections on l and run a debug session on each, ServeListener
+ * returns once l is closed
+ */
+func ServeListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			Serve(conn, conn)
+		}()
+	}
+}
+
+// handle one request, returns false once the client disconnected
+func (s *Server) handle(body []byte) bool {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.write(&response{Type: "response", Command: "unknown", Message: err.Error()})
+		return true
+	}
+
+	if req.Command == "disconnect" {
+		s.stop()
+		s.reply(req, nil, nil)
+		return false
+	}
+
+	result, err := s.dispatch(req)
+	s.reply(req, result, err)
+
+	// the initialized event follows the answer to initialize
+	if req.Command == "initialize" && err == nil {
+		s.event("initialized", nil)
+	}
+
+	return true
+}
+
+func (s *Server) dispatch(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+
+	case "launch":
+		var args LaunchArguments
+		return decode(req.Arguments, &args, func() (any, error) { return nil, s.launch(args) })
+
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		return decode(req.Arguments, &args, func() (any, error) { return s.setBreakpoints(args), nil })
+
+	case "configurationDone":
+		s.configured = true
+		s.start()
+		return nil, nil
+
+	case "threads":
+		return map[string]any{"threads": []Thread{{ID: THREAD_ID, Name: "main"}}}, nil
+
+	case "stackTrace":
+		var args StackTraceArguments
+		return decode(req.Arguments, &args, func() (any, error) { return s.stackTrace(args) })
+
+	case "scopes":
+		var args ScopesArguments
+		return decode(req.Arguments, &args, func() (any, error) { return s.scopes(args) })
+
+	case "variables":
+		var args VariablesArguments
+		return decode(req.Arguments, &args, func() (any, error) { return s.variables(args) })
+
+	case "evaluate":
+		var args EvaluateArguments
+		return decode(req.Arguments, &args, func() (any, error) { return s.evaluate(args) })
+
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, s.proceed(s.debugger.Continue)
+	case "next":
+		return nil, s.proceed(s.debugger.StepOver)
+	case "stepIn":
+		return nil, s.proceed(s.debugger.StepInto)
+	case "stepOut":
+		return nil, s.proceed(s.debugger.StepOut)
+
+	case "pause":
+		s.debugger.Interrupt()
+		return nil, nil
+
+	case "terminate":
+		s.quit()
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("command %q is not supported", req.Command)
+	}
+}
+
+// unmarshal raw into args and run handler on success
+func decode(raw json.RawMessage, args any, handler func() (any, error)) (any, error) {
+	if len(raw) != 0 {
+		if err := json.Unmarshal(raw, args); err != nil {
+			return nil, err
+		}
+	}
+
+	return handler()
+}
+
+func (s *Server) reply(req request, body any, err error) {
+	res := &response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		res.Message = err.Error()
+	}
+
+	s.write(res)
+}
+
+func (s *Server) event(name string, body any) {
+	s.write(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) write(msg any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+
+	rpc.WriteMessage(s.out, msg)
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	if s.launched {
+		return errors.New("a script has already been launched")
+	}
+
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if details := p.ErrorDetails(); len(details) != 0 {
+		e := details[0]
+		return fmt.Errorf("%s:%d:%d: %s", args.Program, e.Line, e.Column, e.Message)
+	}
+
+	// modules are known by their absolute path, so is the script
+	if s.path, err = filepath.Abs(args.Program); err != nil {
+		return err
+	}
+	s.program = program
+	s.lines = statementLines(program)
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+	s.launched = true
+
+	s.start()
+	return nil
+}
+
+func statementLines(program *ast.Program) map[int]bool {
+	lines := make(map[int]bool)
+	ast.Inspect(program, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Statement); ok {
+			lines[ast.Start(stmt).Line] = true
+		}
+		return true
+	})
+
+	return lines
+}
+
+// the script runs once it is launched and the client is done configuring
+func (s *Server) start() {
+	if !s.launched || !s.configured || s.started {
+		return
+	}
+	s.started = true
+
+	if !s.stopOnEntry {
+		s.debugger.Continue()
+	}
+
+	go s.run()
+}
+
+func (s *Server) run() {
+	defer close(s.done)
+
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Out: &output{s}})
+	env.SetFile(s.path)
+	if s.noDebug {
+		env.SetTracer(quitTracer{s})
+	} else {
+		s.debugger.Attach(env)
+	}
+
+	result := evaluator.Eval(s.program, env)
+
+	s.state.Lock()
+	quitting := s.quitting
+	s.state.Unlock()
+
+	code := 0
+	if err, ok := result.(*object.Error); ok && !quitting {
+		s.event("output", OutputEvent{Category: "stderr", Output: err.Inspect() + "\n"})
+		code = 1
+	}
+
+	s.event("exited", ExitedEvent{ExitCode: code})
+	s.event("terminated", nil)
+}
+
+// quitTracer follows a script run without debugging only so that it can be
+// stopped
+type quitTracer struct {
+	s *Server
+}
+
+func (q quitTracer) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	q.s.state.Lock()
+	defer q.s.state.Unlock()
+
+	// a new error every time, the evaluator gives it the position it
+	// stopped at and sessions run side by side
+	if q.s.quitting {
+		return &object.Error{Message: "evaluation stopped"}
+	}
+	return nil
+}
+
+func (q quitTracer) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {}
+func (q quitTracer) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object)     {}
+
+// output forwards what the script prints to the client
+type output struct {
+	s *Server
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.s.event("output", OutputEvent{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
+
+// called on the evaluating goroutine, waits until the client resumes
+func (s *Server) pause(reason string) {
+	s.state.Lock()
+	s.paused = true
+	s.state.Unlock()
+
+	s.event("stopped", StoppedEvent{Reason: reason, ThreadID: THREAD_ID, AllThreadsStopped: true})
+	<-s.resume
+}
+
+func (s *Server) isPaused() bool {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	return s.paused
+}
+
+// apply a stepping action to the stopped script and let it run again
+func (s *Server) proceed(action func()) error {
+	if !s.isPaused() {
+		return errors.New("the script is not stopped")
+	}
+
+	action()
+	s.handles = nil
+
+	s.state.Lock()
+	s.paused = false
+	s.state.Unlock()
+
+	// the answer goes out before the script gets to send any event
+	go func() { s.resume <- struct{}{} }()
+	return nil
+}
+
+// stop the script, it ends with the next statement it reaches
+func (s *Server) quit() {
+	s.state.Lock()
+	s.quitting = true
+	s.state.Unlock()
+
+	s.debugger.Quit()
+	if s.isPaused() {
+		s.proceed(func() {})
+	}
+}
+
+// stop the script and wait until it has ended
+func (s *Server) stop() {
+	if !s.started {
+		return
+	}
+
+	s.quit()
+	<-s.done
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) any {
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		path = args.Source.Path
+	}
+	s.debugger.ClearBreakpoints(path)
+
+	breakpoints := []Breakpoint{}
+	for _, sb := range args.Breakpoints {
+		bp := Breakpoint{Line: sb.Line, Verified: true}
+
+		// only the launched script has been parsed, breakpoints in other
+		// files are kept but stay unverified until their module loads
+		switch {
+		case !s.launched:
+			s.debugger.SetBreakpoint(path, sb.Line)
+		case path != s.path:
+			bp.Verified = false
+			bp.Message = "the module is not loaded yet"
+			s.debugger.SetBreakpoint(path, sb.Line)
+		case !s.lines[sb.Line]:
+			bp.Verified = false
+			bp.Message = "no statement starts on this line"
+		default:
+			s.debugger.SetBreakpoint(path, sb.Line)
+		}
+
+		breakpoints = append(breakpoints, bp)
+	}
+
+	return map[string]any{"breakpoints": breakpoints}
+}
+
+func (s *Server) stackTrace(args StackTraceArguments) (any, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the script is not stopped")
+	}
+
+	frames := s.debugger.Frames()
+
+	stack := []StackFrame{}
+	for i, frame := range frames {
+		if i < args.StartFrame || args.Levels > 0 && len(stack) == args.Levels {
+			continue
+		}
+		// frames which have not run a statement yet have no file
+		path := frame.File
+		if path == "" {
+			path = s.path
+		}
+		source := &Source{Name: filepath.Base(path), Path: path}
+		stack = append(stack, StackFrame{ID: i + 1, Name: frame.Name, Source: source, Line: frame.Line, Column: 1})
+	}
+
+	return map[string]any{"stackFrames": stack, "totalFrames": len(frames)}, nil
+}
+
+// the stopped frame with the given id, ids are the frame index + 1
+func (s *Server) frame(id int) (*debugger.Frame, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the script is not stopped")
+	}
+
+	frames := s.debugger.Frames()
+	if id < 1 || id > len(frames) || frames[id-1].Env == nil {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+
+	return frames[id-1], nil
+}
+
+// each environment around a frame is a scope, the outermost holds the globals
+func (s *Server) scopes(args ScopesArguments) (any, error) {
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		if env.Outer() == nil {
+			name = "Globals"
+		} else if env == frame.Env {
+			name = "Locals"
+		}
+
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.reference(env)})
+	}
+
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) reference(value any) int {
+	s.handles = append(s.handles, value)
+	return len(s.handles)
+}
+
+func (s *Server) variables(args VariablesArguments) (any, error) {
+	if !s.isPaused() {
+		return nil, errors.New("the script is not stopped")
+	}
+
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(s.handles) {
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+
+	variables := []Variable{}
+	switch value := s.handles[ref-1].(type) {
+	case *object.Environment:
+		for _, name := range value.LocalNames() {
+			obj, _ := value.Get(name)
+			variables = append(variables, s.variable(name, obj))
+		}
+
+	case *object.Array:
+		for i, element := range value.Elements {
+			variables = append(variables, s.variable("["+strconv.Itoa(i)+"]", element))
+		}
+
+	case *object.Hash:
+		for _, pair := range value.Pairs() {
+			variables = append(variables, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+	}
+
+	return map[string]any{"variables": variables}, nil
+}
+
+// describe obj, arrays and hashes get a reference to expand them
+func (s *Server) variable(name string, obj object.Object) Variable {
+	v := Variable{Name: name, Value: obj.Inspect(), Type: string(obj.Type())}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) > 0 {
+			v.VariablesReference = s.reference(obj)
+		}
+	case *object.Hash:
+		if obj.Len() > 0 {
+			v.VariablesReference = s.reference(obj)
+		}
+	}
+
+	return v
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (any, error) {
+	id := args.FrameID
+	if id == 0 {
+		id = 1
+	}
+
+	if _, err := s.frame(id); err != nil {
+		return nil, err
+	}
+
+	result := s.debugger.Evaluate(args.Expression, id-1)
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	if result == nil {
+		result = &object.Null{}
+	}
+
+	v := s.variable("", result)
+	return map[string]any{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
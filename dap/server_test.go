@@ -0,0 +1,527 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"interpreter/rpc"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const script = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let pair = [add(1, 2), {"k": 3}];
+put("between");
+let total = add(pair[0], 4);
+total
+`
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a server running in the same process over pipes
+type client struct {
+	t        *testing.T
+	toServer *io.PipeWriter
+	messages chan message
+	done     chan error
+	nextSeq  int
+	// events received while waiting for something else
+	events []message
+	// everything the script printed so far
+	out  strings.Builder
+	path string
+}
+
+func newClient(t *testing.T, source string) *client {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+
+	c := &client{
+		t:        t,
+		toServer: toServer,
+		messages: make(chan message, 16),
+		done:     make(chan error, 1),
+		path:     path,
+	}
+
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	// keep reading so the server never blocks on writing
+	go func() {
+		r := bufio.NewReader(fromServer)
+		for {
+			body, err := rpc.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("server sent invalid JSON %q", body)
+				continue
+			}
+			c.messages <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) next() message {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("server hung up")
+		}
+		if msg.Event == "output" {
+			var body OutputEvent
+			json.Unmarshal(msg.Body, &body)
+			c.out.WriteString(body.Output)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+		return message{}
+	}
+}
+
+// send a request and wait for its response, decoding the body into result
+func (c *client) call(command string, args any, result any) message {
+	c.t.Helper()
+
+	c.nextSeq++
+	seq := c.nextSeq
+	req := map[string]any{"seq": seq, "type": "request", "command": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+	if err := rpc.WriteMessage(c.toServer, req); err != nil {
+		c.t.Fatalf("sending %s failed: %v", command, err)
+	}
+
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+
+		if msg.RequestSeq != seq || msg.Command != command {
+			c.t.Fatalf("answer for %s (%d) while waiting for %s (%d)", msg.Command, msg.RequestSeq, command, seq)
+		}
+		if result != nil && msg.Success {
+			if err := json.Unmarshal(msg.Body, result); err != nil {
+				c.t.Fatalf("decoding body of %s failed: %v", command, err)
+			}
+		}
+		return msg
+	}
+}
+
+// wait for the named event, decoding its body into body
+func (c *client) waitEvent(name string, body any) {
+	c.t.Helper()
+
+	for {
+		var msg message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+
+		if msg.Type == "event" && msg.Event == name {
+			if body != nil {
+				json.Unmarshal(msg.Body, body)
+			}
+			return
+		}
+	}
+}
+
+// initialize, set breakpoints on lines and launch the script
+func (c *client) launch(stopOnEntry bool, lines ...int) []Breakpoint {
+	c.t.Helper()
+
+	if msg := c.call("initialize", map[string]any{"adapterID": "monkey"}, nil); !msg.Success {
+		c.t.Fatalf("initialize failed: %s", msg.Message)
+	}
+	c.waitEvent("initialized", nil)
+
+	if msg := c.call("launch", map[string]any{"program": c.path, "stopOnEntry": stopOnEntry}, nil); !msg.Success {
+		c.t.Fatalf("launch failed: %s", msg.Message)
+	}
+
+	breakpoints := []map[string]any{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, map[string]any{"line": line})
+	}
+	var body struct{ Breakpoints []Breakpoint }
+	c.call("setBreakpoints", map[string]any{"source": map[string]any{"path": c.path}, "breakpoints": breakpoints}, &body)
+
+	c.call("configurationDone", nil, nil)
+	return body.Breakpoints
+}
+
+func (c *client) stopped() StoppedEvent {
+	c.t.Helper()
+
+	var stopped StoppedEvent
+	c.waitEvent("stopped", &stopped)
+	return stopped
+}
+
+func (c *client) stack() []StackFrame {
+	c.t.Helper()
+
+	var body struct{ StackFrames []StackFrame }
+	c.call("stackTrace", map[string]any{"threadId": THREAD_ID}, &body)
+	return body.StackFrames
+}
+
+func (c *client) close() {
+	c.call("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("server stopped with %v", err)
+	}
+	c.toServer.Close()
+}
+
+func TestBreakpointsAndStackTrace(t *testing.T) {
+	c := newClient(t, script)
+	defer c.close()
+
+	breakpoints := c.launch(false, 2, 4, 7)
+	if len(breakpoints) != 3 || !breakpoints[0].Verified || breakpoints[1].Verified || !breakpoints[2].Verified {
+		t.Errorf("only lines with a statement should be verified, got %+v", breakpoints)
+	}
+
+	if stopped := c.stopped(); stopped.Reason != "breakpoint" || stopped.ThreadID != THREAD_ID {
+		t.Errorf("unexpected stop %+v", stopped)
+	}
+
+	stack := c.stack()
+	if len(stack) != 2 || stack[0].Name != "add" || stack[0].Line != 2 || stack[1].Name != "<program>" || stack[1].Line != 5 {
+		t.Fatalf("unexpected stack %+v", stack)
+	}
+	if stack[0].Source == nil || stack[0].Source.Path != c.path {
+		t.Errorf("frames should point at the script, got %+v", stack[0].Source)
+	}
+
+	var threads struct{ Threads []Thread }
+	c.call("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != THREAD_ID {
+		t.Errorf("unexpected threads %+v", threads)
+	}
+
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	c.stopped()
+	if stack := c.stack(); len(stack) != 1 || stack[0].Line != 7 {
+		t.Errorf("expected to stop on line 7, got %+v", stack)
+	}
+
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	c.stopped()
+	if stack := c.stack(); stack[0].Line != 2 || stack[1].Line != 7 {
+		t.Errorf("expected to stop in the second call of add, got %+v", stack)
+	}
+
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	var exited ExitedEvent
+	c.waitEvent("exited", &exited)
+	c.waitEvent("terminated", nil)
+
+	if exited.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", exited.ExitCode)
+	}
+}
+
+func TestScopesAndVariables(t *testing.T) {
+	c := newClient(t, script)
+	defer c.close()
+
+	c.launch(false, 6)
+	c.stopped()
+
+	var scopes struct{ Scopes []Scope }
+	c.call("scopes", map[string]any{"frameId": c.stack()[0].ID}, &scopes)
+	if len(scopes.Scopes) != 1 || scopes.Scopes[0].Name != "Globals" {
+		t.Fatalf("unexpected scopes %+v", scopes)
+	}
+
+	variables := func(ref int) map[string]Variable {
+		var body struct{ Variables []Variable }
+		c.call("variables", map[string]any{"variablesReference": ref}, &body)
+		found := make(map[string]Variable)
+		for _, v := range body.Variables {
+			found[v.Name] = v
+		}
+		return found
+	}
+
+	globals := variables(scopes.Scopes[0].VariablesReference)
+	pair, ok := globals["pair"]
+	if !ok || pair.Type != "ARRAY" || pair.VariablesReference == 0 {
+		t.Fatalf("pair should be an expandable array, got %+v", globals)
+	}
+	if globals["add"].Type != "FUNCTION" {
+		t.Errorf("add should be a function, got %+v", globals["add"])
+	}
+
+	elements := variables(pair.VariablesReference)
+	if elements["[0]"].Value != "3" || elements["[1]"].VariablesReference == 0 {
+		t.Fatalf("unexpected elements %+v", elements)
+	}
+	if hash := variables(elements["[1]"].VariablesReference); hash["k"].Value != "3" {
+		t.Errorf("unexpected hash entries %+v", hash)
+	}
+
+	c.call("setBreakpoints", map[string]any{"source": map[string]any{"path": c.path}, "breakpoints": []map[string]any{{"line": 3}}}, nil)
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	c.stopped()
+
+	c.call("scopes", map[string]any{"frameId": c.stack()[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("unexpected scopes %+v", scopes)
+	}
+	locals := variables(scopes.Scopes[0].VariablesReference)
+	if locals["a"].Value != "3" || locals["b"].Value != "4" || locals["sum"].Value != "7" {
+		t.Errorf("unexpected locals %+v", locals)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	c := newClient(t, script)
+	defer c.close()
+
+	c.launch(true)
+	if stopped := c.stopped(); stopped.Reason != "entry" {
+		t.Errorf("expected to stop on entry, got %+v", stopped)
+	}
+
+	steps := []struct {
+		command string
+		lines   []int
+	}{
+		{"next", []int{5}},
+		{"stepIn", []int{2, 5}},
+		{"next", []int{3, 5}},
+		{"stepOut", []int{6}},
+		{"next", []int{7}},
+		{"next", []int{8}},
+	}
+
+	for _, step := range steps {
+		c.call(step.command, map[string]any{"threadId": THREAD_ID}, nil)
+		if stopped := c.stopped(); stopped.Reason != "step" {
+			t.Errorf("%s: unexpected stop %+v", step.command, stopped)
+		}
+
+		lines := []int{}
+		for _, frame := range c.stack() {
+			lines = append(lines, frame.Line)
+		}
+		if len(lines) != len(step.lines) || lines[0] != step.lines[0] {
+			t.Errorf("%s: stopped at %v, but want %v", step.command, lines, step.lines)
+		}
+	}
+
+	if out := c.out.String(); out != "between\n" {
+		t.Errorf("script output got %q", out)
+	}
+}
+
+func TestModuleFrames(t *testing.T) {
+	c := newClient(t, "import \"lib.monkey\" as lib;\nlib[\"f\"]()\n")
+	defer c.close()
+
+	lib := filepath.Join(filepath.Dir(c.path), "lib.monkey")
+	if err := os.WriteFile(lib, []byte("export let f = fn() {\n  1\n};\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.launch(false, 1, 2)
+	c.stopped()
+	if stack := c.stack(); len(stack) != 1 || stack[0].Line != 1 || stack[0].Source.Path != c.path {
+		t.Errorf("expected to stop once on line 1 of the script, got %+v", stack)
+	}
+
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	c.stopped()
+	c.call("stepIn", map[string]any{"threadId": THREAD_ID}, nil)
+	c.stopped()
+
+	stack := c.stack()
+	if len(stack) != 2 || stack[0].Line != 2 || stack[0].Source.Path != lib || stack[1].Source.Path != c.path {
+		t.Errorf("expected f in lib.monkey called from the script, got %+v", stack)
+	}
+}
+
+func TestModuleBreakpoints(t *testing.T) {
+	c := newClient(t, "import \"lib.monkey\" as lib;\nlib[\"f\"]()\n")
+	defer c.close()
+
+	lib := filepath.Join(filepath.Dir(c.path), "lib.monkey")
+	if err := os.WriteFile(lib, []byte("export let f = fn() {\n  1\n};\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.launch(true)
+	c.stopped()
+
+	var body struct{ Breakpoints []Breakpoint }
+	breakpoints := []map[string]any{{"line": 2}}
+	c.call("setBreakpoints", map[string]any{"source": map[string]any{"path": lib}, "breakpoints": breakpoints}, &body)
+	if len(body.Breakpoints) != 1 || body.Breakpoints[0].Verified {
+		t.Errorf("expected an unverified breakpoint in the module, got %+v", body.Breakpoints)
+	}
+
+	c.call("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	if stopped := c.stopped(); stopped.Reason != "breakpoint" {
+		t.Errorf("expected to stop on the breakpoint, got %+v", stopped)
+	}
+	if stack := c.stack(); len(stack) != 2 || stack[0].Line != 2 || stack[0].Source.Path != lib {
+		t.Errorf("expected to stop on line 2 of lib.monkey, got %+v", stack)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := newClient(t, script)
+	defer c.close()
+
+	c.launch(false, 3)
+	c.stopped()
+	stack := c.stack()
+
+	var result struct {
+		Result string
+		Type   string
+	}
+	c.call("evaluate", map[string]any{"expression": "sum * 10", "frameId": stack[0].ID, "context": "repl"}, &result)
+	if result.Result != "30" || result.Type != "INTEGER" {
+		t.Errorf("evaluating in add got %+v", result)
+	}
+
+	c.call("evaluate", map[string]any{"expression": "add(10, 20)", "frameId": stack[1].ID, "context": "repl"}, &result)
+	if result.Result != "30" {
+		t.Errorf("evaluating in the program frame got %+v", result)
+	}
+
+	msg := c.call("evaluate", map[string]any{"expression": "missing", "frameId": stack[0].ID}, nil)
+	if msg.Success || !strings.Contains(msg.Message, "not found: missing") {
+		t.Errorf("expected an error for an unknown name, got %+v", msg)
+	}
+
+	msg = c.call("evaluate", map[string]any{"expression": "sum", "frameId": 9}, nil)
+	if msg.Success {
+		t.Errorf("expected an error for an unknown frame")
+	}
+}
+
+func TestRequestsWhileRunning(t *testing.T) {
+	c := newClient(t, "let loop = fn(n) { loop(n + 1) };\nloop(0)\n")
+	defer c.close()
+
+	c.launch(false)
+
+	if msg := c.call("next", map[string]any{"threadId": THREAD_ID}, nil); msg.Success {
+		t.Errorf("stepping a running script should fail")
+	}
+
+	c.call("pause", map[string]any{"threadId": THREAD_ID}, nil)
+	if stopped := c.stopped(); stopped.Reason != "pause" {
+		t.Errorf("unexpected stop %+v", stopped)
+	}
+	if stack := c.stack(); len(stack) < 2 || stack[0].Name != "loop" {
+		t.Errorf("expected to be inside loop, got %d frames", len(stack))
+	}
+
+	c.call("terminate", nil, nil)
+	c.waitEvent("terminated", nil)
+}
+
+func TestTerminateWithoutDebugging(t *testing.T) {
+	c := newClient(t, "let spin = fn() { map(range(1000000), fn(x) { map(range(1000000), fn(y) { y }) }) };\nspin()\n")
+	defer c.close()
+
+	c.call("initialize", map[string]any{"adapterID": "monkey"}, nil)
+	if msg := c.call("launch", map[string]any{"program": c.path, "noDebug": true}, nil); !msg.Success {
+		t.Fatalf("launch failed: %s", msg.Message)
+	}
+	c.call("configurationDone", nil, nil)
+
+	c.call("terminate", nil, nil)
+	c.waitEvent("terminated", nil)
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t, "let = 1;\n")
+	defer c.close()
+
+	c.call("initialize", map[string]any{}, nil)
+	msg := c.call("launch", map[string]any{"program": c.path}, nil)
+	if msg.Success || !strings.Contains(msg.Message, ":1:5: ") {
+		t.Errorf("launching a broken script should report its position, got %+v", msg)
+	}
+
+	if msg := c.call("restart", nil, nil); msg.Success {
+		t.Errorf("unsupported commands should fail")
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- ServeListener(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rpc.WriteMessage(conn, map[string]any{"seq": 1, "type": "request", "command": "initialize", "arguments": map[string]any{}})
+	body, err := rpc.ReadMessage(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg message
+	json.Unmarshal(body, &msg)
+	if msg.Type != "response" || msg.Command != "initialize" || !msg.Success {
+		t.Errorf("unexpected answer %s", body)
+	}
+
+	l.Close()
+	if err := <-done; err != nil {
+		t.Errorf("ServeListener returned %v after closing", err)
+	}
+}
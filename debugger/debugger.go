@@ -0,0 +1,323 @@
+package debugger
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// why the evaluation was paused
+const (
+	StopEntry      = "entry"
+	StopStep       = "step"
+	StopBreakpoint = "breakpoint"
+	StopPause      = "pause"
+)
+
+// Frame is one active call, the outermost frame is the program itself
+type Frame struct {
+	Name string
+	// the call expression which opened the frame, nil for the program
+	Call *ast.CallExpression
+	// environment, file and line of the statement last reached in this
+	// frame, the file is empty for a script read from nowhere
+	Env  *object.Environment
+	File string
+	Line int
+}
+
+// a line of a file, named the way the environment running it records it
+type location struct {
+	file string
+	line int
+}
+
+type mode int
+
+const (
+	modeContinue mode = iota
+	modeStepInto
+	modeStepOver
+	modeStepOut
+)
+
+/**
+ * Debugger is an object.Tracer pausing the evaluation on breakpoints and
+ * while stepping, pause is called on the evaluating goroutine whenever it
+ * stops and the evaluation carries on once pause returns, a frontend decides
+ * how to go on by calling Continue, StepInto, StepOver, StepOut or Quit
+ * before returning, breakpoints, Interrupt and Quit may also be used from
+ * other goroutines while the evaluation runs
+ */
+type Debugger struct {
+	pause func(reason string)
+
+	// guards breakpoints, interrupt and quit
+	mu          sync.Mutex
+	breakpoints map[location]bool
+	interrupt   bool
+	quit        bool
+
+	frames []*Frame
+
+	mode      mode
+	modeDepth int
+
+	// line, file and depth of the statement run last, a breakpoint only
+	// triggers when a line is entered
+	prevLine  int
+	prevFile  string
+	prevDepth int
+
+	evaluating bool
+	// set once the first pause happened, that one is reported as the entry
+	started bool
+}
+
+// the debugger stops before the first statement unless Continue is called first
+func New(pause func(reason string)) *Debugger {
+	return &Debugger{
+		pause:       pause,
+		breakpoints: make(map[location]bool),
+		frames:      []*Frame{{Name: "<program>"}},
+		mode:        modeStepInto,
+	}
+}
+
+// Attach makes env and every evaluation in it report to the debugger
+func (d *Debugger) Attach(env *object.Environment) {
+	env.SetTracer(d)
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if d.evaluating {
+		return nil
+	}
+
+	line, file := ast.Start(stmt).Line, env.File()
+
+	d.mu.Lock()
+	quit, interrupt, breakpoint := d.quit, d.interrupt, d.breakpoints[location{file, line}]
+	d.interrupt = false
+	d.mu.Unlock()
+
+	if quit {
+		return stopped()
+	}
+
+	depth := len(d.frames)
+	current := d.frames[depth-1]
+	current.Env = env
+	current.File = file
+	current.Line = line
+
+	entered := line != d.prevLine || file != d.prevFile || depth != d.prevDepth
+	d.prevLine, d.prevFile, d.prevDepth = line, file, depth
+
+	reason := ""
+	switch {
+	case interrupt:
+		reason = StopPause
+	case d.mode == modeStepInto:
+		reason = StopStep
+	case d.mode == modeStepOver && depth <= d.modeDepth:
+		reason = StopStep
+	case d.mode == modeStepOut && depth < d.modeDepth:
+		reason = StopStep
+	case breakpoint && entered:
+		reason = StopBreakpoint
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	if !d.started {
+		d.started = true
+		if reason == StopStep {
+			reason = StopEntry
+		}
+	}
+
+	d.mode = modeContinue
+	d.pause(reason)
+
+	if d.stopping() {
+		return stopped()
+	}
+	return nil
+}
+
+// a new error every time, the evaluator gives it the position it stopped at
+func stopped() *object.Error {
+	return &object.Error{Message: "debugger: evaluation stopped"}
+}
+
+func (d *Debugger) stopping() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.quit
+}
+
+func (d *Debugger) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	d.frames = append(d.frames, &Frame{Name: call.Function.String(), Call: call, File: env.File(), Line: ast.Start(call).Line})
+}
+
+func (d *Debugger) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object) {
+	if d.evaluating || len(d.frames) == 1 {
+		return
+	}
+
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// resume until the next breakpoint
+func (d *Debugger) Continue() {
+	d.mode = modeContinue
+}
+
+// stop at the very next statement, entering calls
+func (d *Debugger) StepInto() {
+	d.mode = modeStepInto
+}
+
+// stop at the next statement of the current frame or of a frame further out
+func (d *Debugger) StepOver() {
+	d.mode = modeStepOver
+	d.modeDepth = len(d.frames)
+}
+
+// stop once the current frame has returned
+func (d *Debugger) StepOut() {
+	d.mode = modeStepOut
+	d.modeDepth = len(d.frames)
+}
+
+// abandon the evaluation, every statement still to run fails
+func (d *Debugger) Quit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.quit = true
+}
+
+// stop at the next statement whatever the evaluation is doing
+func (d *Debugger) Interrupt() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.interrupt = true
+}
+
+// breakpoints are set on a line of file, the path the script or module
+// running there is known by
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints[location{file, line}] = true
+}
+
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints, location{file, line})
+}
+
+// remove every breakpoint of file
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for at := range d.breakpoints {
+		if at.file == file {
+			delete(d.breakpoints, at)
+		}
+	}
+}
+
+func (d *Debugger) HasBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.breakpoints[location{file, line}]
+}
+
+// lines of file holding a breakpoint in ascending order
+func (d *Debugger) Breakpoints(file string) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []int{}
+	for at := range d.breakpoints {
+		if at.file == file {
+			lines = append(lines, at.line)
+		}
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// active frames, the innermost first
+func (d *Debugger) Frames() []*Frame {
+	frames := make([]*Frame, 0, len(d.frames))
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		frames = append(frames, d.frames[i])
+	}
+
+	return frames
+}
+
+/**
+ * evaluate source in the environment of the frame at index, counted from the
+ * innermost one, without stopping at breakpoints on the way
+ */
+func (d *Debugger) Evaluate(source string, index int) object.Object {
+	frames := d.Frames()
+	if index < 0 || index >= len(frames) || frames[index].Env == nil {
+		return &object.Error{Message: "no such frame"}
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return &object.Error{Message: strings.Join(p.Errors(), "; ")}
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	return evaluator.Eval(program, frames[index].Env)
+}
+
+// bindings of env and each environment around it, the innermost first
+func Scopes(env *object.Environment) [][]Binding {
+	scopes := [][]Binding{}
+	for scope := env; scope != nil; scope = scope.Outer() {
+		bindings := []Binding{}
+		for _, name := range scope.LocalNames() {
+			value, _ := scope.Get(name)
+			bindings = append(bindings, Binding{Name: name, Value: value})
+		}
+		scopes = append(scopes, bindings)
+	}
+
+	return scopes
+}
+
+type Binding struct {
+	Name  string
+	Value object.Object
+}
@@ -0,0 +1,256 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const script = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 3);
+y
+`
+
+type stop struct {
+	reason string
+	line   int
+	depth  int
+}
+
+/**
+ * run script with a debugger which records every stop and then applies the
+ * next action, the debugger continues once the actions are used up
+ */
+func debugScript(t *testing.T, setup func(d *Debugger), actions ...func(d *Debugger)) ([]stop, object.Object) {
+	t.Helper()
+
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors %v", p.Errors())
+	}
+
+	stops := []stop{}
+	var d *Debugger
+	d = New(func(reason string) {
+		frames := d.Frames()
+		stops = append(stops, stop{reason, frames[0].Line, len(frames)})
+
+		if len(actions) == 0 {
+			d.Continue()
+			return
+		}
+		actions[0](d)
+		actions = actions[1:]
+	})
+	if setup != nil {
+		setup(d)
+	}
+
+	env := object.NewEnvironment()
+	d.Attach(env)
+	return stops, evaluator.Eval(program, env)
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []func(d *Debugger)
+		expected []stop
+	}{
+		{
+			"step into",
+			[]func(d *Debugger){(*Debugger).StepInto, (*Debugger).StepInto, (*Debugger).StepInto},
+			[]stop{{StopEntry, 1, 1}, {StopStep, 5, 1}, {StopStep, 2, 2}, {StopStep, 3, 2}},
+		},
+		{
+			"step over",
+			[]func(d *Debugger){(*Debugger).StepOver, (*Debugger).StepOver, (*Debugger).StepOver},
+			[]stop{{StopEntry, 1, 1}, {StopStep, 5, 1}, {StopStep, 6, 1}, {StopStep, 7, 1}},
+		},
+		{
+			"step out",
+			[]func(d *Debugger){(*Debugger).StepOver, (*Debugger).StepInto, (*Debugger).StepOut},
+			[]stop{{StopEntry, 1, 1}, {StopStep, 5, 1}, {StopStep, 2, 2}, {StopStep, 6, 1}},
+		},
+	}
+
+	for _, test := range tests {
+		stops, result := debugScript(t, nil, test.actions...)
+		if !equalStops(stops, test.expected) {
+			t.Errorf("%s: stops got %v, but want %v", test.name, stops, test.expected)
+		}
+		testIntegerResult(t, result, 6)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	setup := func(d *Debugger) {
+		d.Continue()
+		d.SetBreakpoint("", 2)
+		d.SetBreakpoint("", 7)
+	}
+
+	stops, result := debugScript(t, setup)
+	expected := []stop{{StopBreakpoint, 2, 2}, {StopBreakpoint, 2, 2}, {StopBreakpoint, 7, 1}}
+	if !equalStops(stops, expected) {
+		t.Errorf("stops got %v, but want %v", stops, expected)
+	}
+	testIntegerResult(t, result, 6)
+}
+
+// a module runs under the debugger of its importer, its lines are not those
+// of the script
+func TestBreakpointsInModules(t *testing.T) {
+	dir := t.TempDir()
+	main, lib := filepath.Join(dir, "main.monkey"), filepath.Join(dir, "lib.monkey")
+	files := map[string]string{
+		main: "import \"lib.monkey\" as lib;\nlet x = lib[\"f\"]();\nx\n",
+		lib:  "export let a = 1;\nexport let f = fn() {\n  a + 1\n};\n",
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stops := []string{}
+	var d *Debugger
+	d = New(func(reason string) {
+		frame := d.Frames()[0]
+		stops = append(stops, fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line))
+		d.Continue()
+	})
+	d.Continue()
+	d.SetBreakpoint(main, 1)
+	d.SetBreakpoint(lib, 3)
+
+	program := parser.New(lexer.New(files[main])).ParseProgram()
+	env := object.NewEnvironment()
+	env.SetFile(main)
+	d.Attach(env)
+	result := evaluator.Eval(program, env)
+
+	expected := "main.monkey:1 lib.monkey:3"
+	if got := strings.Join(stops, " "); got != expected {
+		t.Errorf("stops got %s, but want %s", got, expected)
+	}
+	testIntegerResult(t, result, 2)
+}
+
+func TestEvaluateInFrame(t *testing.T) {
+	setup := func(d *Debugger) {
+		d.Continue()
+		d.SetBreakpoint("", 3)
+	}
+
+	var inner, outer object.Object
+	var scopes [][]Binding
+	debugScript(t, setup, func(d *Debugger) {
+		inner = d.Evaluate("sum * 10", 0)
+		outer = d.Evaluate("add(10, 20)", 1)
+		scopes = Scopes(d.Frames()[0].Env)
+		d.ClearBreakpoints("")
+		d.Continue()
+	})
+
+	testIntegerResult(t, inner, 30)
+	testIntegerResult(t, outer, 30)
+
+	if len(scopes) != 2 {
+		t.Fatalf("expected the local and the global scope, got %v", scopes)
+	}
+	names := []string{}
+	for _, b := range scopes[0] {
+		names = append(names, b.Name)
+	}
+	if strings.Join(names, ",") != "a,b,sum" {
+		t.Errorf("local scope got %v", names)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	stops, result := debugScript(t, nil, (*Debugger).StepOver, (*Debugger).Quit)
+	if len(stops) != 2 {
+		t.Errorf("expected two stops before quitting, got %v", stops)
+	}
+
+	err, ok := result.(*object.Error)
+	if !ok || !strings.Contains(err.Message, "stopped") {
+		t.Errorf("expected the evaluation to fail, got %v", result)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+
+	commands := "b 6\nbreakpoints\nc\np x\nstep\nbt\nenv\nlist\nfinish\nbogus\nc\n"
+	var out bytes.Buffer
+	term := NewTerminal(script, strings.NewReader(commands), &out)
+	result := term.Run(program, object.NewEnvironment())
+	testIntegerResult(t, result, 6)
+
+	expected := []string{
+		"entry at line 1 in <program>",
+		"breakpoint set on line 6",
+		"breakpoint at line 6 in <program>",
+		"(debug) 3\n",
+		"step at line 2 in add",
+		"#0 add at line 2\n#1 <program> at line 6",
+		"[0 local]\n  a = 3\n  b = 3\n[1 global]\n",
+		"=>    2    let sum = a + b;",
+		"step at line 7 in <program>",
+		`unknown command "bogus"`,
+		"program finished: 6",
+	}
+
+	output := out.String()
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("output is missing %q\n%s", e, output)
+		}
+	}
+}
+
+func TestTerminalQuitsOnEOF(t *testing.T) {
+	p := parser.New(lexer.New(script))
+	var out bytes.Buffer
+	result := NewTerminal(script, strings.NewReader(""), &out).Run(p.ParseProgram(), object.NewEnvironment())
+
+	if _, ok := result.(*object.Error); !ok {
+		t.Errorf("expected the evaluation to stop, got %v", result)
+	}
+}
+
+func equalStops(a, b []stop) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testIntegerResult(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	integer, ok := obj.(*object.Integer)
+	if !ok || integer.Value != expected {
+		t.Errorf("expected %d, got %v", expected, obj)
+	}
+}
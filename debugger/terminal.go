@@ -0,0 +1,225 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const PROMPT = "(debug) "
+
+// lines of source shown around the current one by list
+const LIST_CONTEXT = 3
+
+const TERMINAL_HELP = `commands:
+  break, b <line>     stop whenever <line> is reached
+  delete, d <line>    remove the breakpoint on <line>
+  breakpoints         list the breakpoints
+  step, s             run to the next statement, entering calls
+  next, n             run to the next statement of this call
+  finish, o           run until this call returns
+  continue, c         run to the next breakpoint
+  print, p <expr>     evaluate <expr> in the paused frame
+  env                 print the environment chain of the paused frame
+  stack, bt           print the active calls
+  list, l             print the source around the current line
+  quit, q             stop the program
+`
+
+// Terminal drives a Debugger with commands typed line by line
+type Terminal struct {
+	debugger *Debugger
+	in       *bufio.Scanner
+	out      io.Writer
+	lines    []string
+	// the file of the script being debugged, breakpoints are set in it
+	file string
+}
+
+func NewTerminal(source string, in io.Reader, out io.Writer) *Terminal {
+	t := &Terminal{
+		in:    bufio.NewScanner(in),
+		out:   out,
+		lines: strings.Split(strings.TrimSuffix(source, "\n"), "\n"),
+	}
+	t.debugger = New(t.pause)
+
+	return t
+}
+
+func (t *Terminal) Debugger() *Debugger {
+	return t.debugger
+}
+
+// Run evaluates program in env under the debugger and reports how it ended
+func (t *Terminal) Run(program *ast.Program, env *object.Environment) object.Object {
+	t.file = env.File()
+	t.debugger.Attach(env)
+	result := evaluator.Eval(program, env)
+
+	if result != nil {
+		fmt.Fprintf(t.out, "program finished: %s\n", result.Inspect())
+	} else {
+		fmt.Fprintln(t.out, "program finished")
+	}
+
+	return result
+}
+
+// read commands until one of them resumes the evaluation
+func (t *Terminal) pause(reason string) {
+	frame := t.debugger.Frames()[0]
+	if frame.File != t.file {
+		fmt.Fprintf(t.out, "%s at %s:%d in %s\n", reason, frame.File, frame.Line, frame.Name)
+	} else {
+		fmt.Fprintf(t.out, "%s at line %d in %s\n", reason, frame.Line, frame.Name)
+		t.printLine(frame.Line, true)
+	}
+
+	for {
+		fmt.Fprint(t.out, PROMPT)
+		if !t.in.Scan() {
+			// nobody left to tell the debugger what to do
+			fmt.Fprintln(t.out)
+			t.debugger.Quit()
+			return
+		}
+
+		if t.command(strings.TrimSpace(t.in.Text())) {
+			return
+		}
+	}
+}
+
+// run one command, returns true once the evaluation should go on
+func (t *Terminal) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	d := t.debugger
+
+	switch name {
+	case "":
+		return false
+	case "step", "s":
+		d.StepInto()
+		return true
+	case "next", "n":
+		d.StepOver()
+		return true
+	case "finish", "out", "o":
+		d.StepOut()
+		return true
+	case "continue", "c":
+		d.Continue()
+		return true
+	case "quit", "q":
+		d.Quit()
+		return true
+
+	case "break", "b":
+		if n, ok := t.lineArgument(arg); ok {
+			d.SetBreakpoint(t.file, n)
+			fmt.Fprintf(t.out, "breakpoint set on line %d\n", n)
+		}
+	case "delete", "d":
+		if n, ok := t.lineArgument(arg); ok {
+			d.ClearBreakpoint(t.file, n)
+			fmt.Fprintf(t.out, "breakpoint removed from line %d\n", n)
+		}
+	case "breakpoints":
+		lines := d.Breakpoints(t.file)
+		if len(lines) == 0 {
+			fmt.Fprintln(t.out, "no breakpoints")
+		}
+		for _, n := range lines {
+			t.printLine(n, false)
+		}
+
+	case "print", "p":
+		if arg == "" {
+			fmt.Fprintln(t.out, "print needs an expression")
+			return false
+		}
+		result := d.Evaluate(arg, 0)
+		if result != nil {
+			fmt.Fprintln(t.out, result.Inspect())
+		}
+	case "env":
+		t.printEnv()
+	case "stack", "bt":
+		for i, frame := range d.Frames() {
+			if frame.File != t.file {
+				fmt.Fprintf(t.out, "#%d %s at %s:%d\n", i, frame.Name, frame.File, frame.Line)
+			} else {
+				fmt.Fprintf(t.out, "#%d %s at line %d\n", i, frame.Name, frame.Line)
+			}
+		}
+	case "list", "l":
+		frame := d.Frames()[0]
+		if frame.File != t.file {
+			fmt.Fprintf(t.out, "the source of %s is not shown\n", frame.File)
+			return false
+		}
+		current := frame.Line
+		for n := max(current-LIST_CONTEXT, 1); n <= min(current+LIST_CONTEXT, len(t.lines)); n++ {
+			t.printLine(n, n == current)
+		}
+
+	case "help", "h":
+		io.WriteString(t.out, TERMINAL_HELP)
+	default:
+		fmt.Fprintf(t.out, "unknown command %q, try help\n", name)
+	}
+
+	return false
+}
+
+func (t *Terminal) lineArgument(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		fmt.Fprintf(t.out, "expected a line number, got %q\n", arg)
+		return 0, false
+	}
+
+	return n, true
+}
+
+// print source line n, marking the current line and breakpoints
+func (t *Terminal) printLine(n int, current bool) {
+	text := ""
+	if n >= 1 && n <= len(t.lines) {
+		text = t.lines[n-1]
+	}
+
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	if t.debugger.HasBreakpoint(t.file, n) {
+		marker = marker[:1] + "*"
+	}
+
+	fmt.Fprintf(t.out, "%s %4d  %s\n", marker, n, text)
+}
+
+func (t *Terminal) printEnv() {
+	scopes := Scopes(t.debugger.Frames()[0].Env)
+	for i, bindings := range scopes {
+		title := "local"
+		if i == len(scopes)-1 {
+			title = "global"
+		} else if i > 0 {
+			title = "enclosing"
+		}
+		fmt.Fprintf(t.out, "[%d %s]\n", i, title)
+
+		for _, b := range bindings {
+			fmt.Fprintf(t.out, "  %s = %s\n", b.Name, b.Value.Inspect())
+		}
+	}
+}
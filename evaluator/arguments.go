@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/object"
+	"regexp"
+	"strings"
+)
+
+// longest string or array a builtin builds at once, asking for more is an
+// error rather than running out of memory
+var maxLength = 1 << 26
+
+// how many arguments a function taking min to max of them wants, max is -1
+// when there is no upper bound
+func wanted(min, max int) string {
+	switch {
+	case max == -1:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+// check the builtin name got min to max arguments, max is -1 for no limit
+func checkArity(name string, args []object.Object, min, max int) *object.Error {
+	if len(args) < min || (max != -1 && len(args) > max) {
+		return newError("wrong number of arguments to `%s` got %d, but wanted %s", name, len(args), wanted(min, max))
+	}
+
+	return nil
+}
+
+var ordinals = []string{"first", "second", "third", "fourth", "fifth"}
+
+// how errors name argument i, "first argument" and so on
+func argumentPosition(i int) string {
+	if i < len(ordinals) {
+		return ordinals[i] + " argument"
+	}
+
+	return fmt.Sprintf("argument %d", i+1)
+}
+
+// the error for argument i of the builtin name not being a want
+func argumentError(name string, i int, want object.ObjectType, got object.Object) *object.Error {
+	return newError("%s to `%s` must be %s, got %s", argumentPosition(i), name, withArticle(want), got.Type())
+}
+
+// "a STRING", "an ARRAY"
+func withArticle(t object.ObjectType) string {
+	if strings.ContainsRune("AEIOU", rune(t[0])) {
+		return "an " + string(t)
+	}
+
+	return "a " + string(t)
+}
+
+func stringArgument(name string, args []object.Object, i int) (string, *object.Error) {
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", argumentError(name, i, object.STRING_OBJ, args[i])
+	}
+
+	return s.Value, nil
+}
+
+func integerArgument(name string, args []object.Object, i int) (int64, *object.Error) {
+	n, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, argumentError(name, i, object.INTEGER_OBJ, args[i])
+	}
+
+	return n.Value, nil
+}
+
+func arrayArgument(name string, args []object.Object, i int) (*object.Array, *object.Error) {
+	arr, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, argumentError(name, i, object.ARRAY_OBJ, args[i])
+	}
+
+	return arr, nil
+}
+
+// fail when the builtin name would build something of length elements
+func checkLength(name string, length int64) *object.Error {
+	if length > int64(maxLength) {
+		return newError("`%s` would build %d elements, more than the limit of %d", name, length, maxLength)
+	}
+
+	return nil
+}
+
+func hashArgument(name string, args []object.Object, i int) (*object.Hash, *object.Error) {
+	hash, ok := args[i].(*object.Hash)
+	if !ok {
+		return nil, argumentError(name, i, object.HASH_OBJ, args[i])
+	}
+
+	return hash, nil
+}
+
+// a function or a builtin, which the builtin name is going to call
+func functionArgument(name string, args []object.Object, i int) (object.Object, *object.Error) {
+	switch args[i].(type) {
+	case *object.Function, *object.Builtin:
+		return args[i], nil
+	default:
+		return nil, argumentError(name, i, object.FUNCTION_OBJ, args[i])
+	}
+}
+
+func regexArgument(name string, args []object.Object, i int) (*regexp.Regexp, *object.Error) {
+	re, ok := args[i].(*object.Regex)
+	if !ok {
+		return nil, argumentError(name, i, object.REGEX_OBJ, args[i])
+	}
+
+	return re.Value, nil
+}
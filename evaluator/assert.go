@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"strconv"
+	"strings"
+)
+
+// the assertions call back into the evaluator, registering them while the
+// builtins table is initialized would make it depend on itself
+func init() {
+	builtins["assert"] = &object.Builtin{Fn: assert}
+	builtins["assert_eq"] = &object.Builtin{Fn: assertEq}
+	builtins["assert_error"] = &object.Builtin{Fn: assertError}
+}
+
+func assert(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("assert", args, 1, 2); err != nil {
+		return err
+	}
+
+	if !isTruthy(args[0]) {
+		return assertionFailed("assert", "got "+describe(args[0]), args[1:])
+	}
+
+	return NULL
+}
+
+func assertEq(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("assert_eq", args, 2, 3); err != nil {
+		return err
+	}
+
+	if !Equal(args[0], args[1]) {
+		return assertionFailed("assert_eq", "got "+describe(args[0])+", but want "+describe(args[1]), args[2:])
+	}
+
+	return NULL
+}
+
+// call fn without arguments and fail unless it ends in an error, whose
+// message has to contain the optional second argument
+func assertError(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("assert_error", args, 1, 2); err != nil {
+		return err
+	}
+
+	switch fn := args[0].(type) {
+	case *object.Function:
+		if min, _ := fn.Arity(); min != 0 {
+			return newError("first argument to `assert_error` must take no arguments, got %d", min)
+		}
+	case *object.Builtin:
+	default:
+		return argumentError("assert_error", 0, object.FUNCTION_OBJ, args[0])
+	}
+
+	want := ""
+	if len(args) == 2 {
+		s, err := stringArgument("assert_error", args, 1)
+		if err != nil {
+			return err
+		}
+		want = s
+	}
+
+	err, ok := Apply(args[0], nil, env).(*object.Error)
+	if !ok {
+		return assertionFailed("assert_error", "the function did not fail", nil)
+	}
+	if !strings.Contains(err.Message, want) {
+		return assertionFailed("assert_error", "got error "+strconv.Quote(err.Message)+", but want one containing "+strconv.Quote(want), nil)
+	}
+
+	return NULL
+}
+
+// the error of a failed assertion, with the message passed by the caller if any
+func assertionFailed(name, detail string, message []object.Object) *object.Error {
+	text := name + " failed: " + detail
+	if len(message) == 1 {
+		if s, ok := message[0].(*object.String); ok {
+			text = s.Value + ": " + text
+		} else {
+			text = message[0].Inspect() + ": " + text
+		}
+	}
+
+	return newError("%s", text)
+}
+
+// strings are quoted so that "1" and 1 tell apart
+func describe(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return strconv.Quote(s.Value)
+	}
+
+	return obj.Inspect()
+}
+
+/**
+ * Equal reports whether a and b are deeply equal, arrays and hashes compare
+ * their elements, functions are only equal to themselves, integers and floats
+ * compare by value like == does
+ */
+func Equal(a, b object.Object) bool {
+	if isNumber(a) && isNumber(b) && a.Type() != b.Type() {
+		return toFloat(a) == toFloat(b)
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value == b.(*object.Integer).Value
+	case *object.Float:
+		return a.Value == b.(*object.Float).Value
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Time:
+		return a.Value.Equal(b.(*object.Time).Value)
+	case *object.Duration:
+		return a.Value == b.(*object.Duration).Value
+	case *object.Regex:
+		return a.Value.String() == b.(*object.Regex).Value.String()
+	case *object.Null:
+		return true
+
+	case *object.Array:
+		other := b.(*object.Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i, element := range a.Elements {
+			if !Equal(element, other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+
+	case *object.Hash:
+		other := b.(*object.Hash)
+		if a.Len() != other.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			otherPair, ok := other.Get(pair.Key.(object.Hashable))
+			if !ok || !Equal(pair.Value, otherPair.Value) {
+				return false
+			}
+		}
+		return true
+
+	case *object.Error:
+		return a.Message == b.(*object.Error).Message
+
+	default:
+		return a == b
+	}
+}
+
+// Apply calls fn with args the way a call expression in env would
+func Apply(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, nil, env)
+}
@@ -3,11 +3,13 @@ package evaluator
 import (
 	"fmt"
 	"interpreter/object"
+	"sort"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments got %d, but wanted %d", len(args), 1)
 			}
@@ -17,8 +19,9 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 
+			// in characters, like the positions of the strings library
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
@@ -26,7 +29,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments got %d, but wanted %d", len(args), 1)
 			}
@@ -42,7 +45,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments got %d, but wanted %d", len(args), 1)
 			}
@@ -59,7 +62,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments got %d, but wanted %d", len(args), 1)
 			}
@@ -67,8 +70,8 @@ var builtins = map[string]*object.Builtin{
 				return newError("argument input is not an array object, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements) - 1
-			if len(arr.Elements) > 0 {
+			length := len(arr.Elements)
+			if length > 0 {
 				newElements := make([]object.Object, length-1, length-1)
 				copy(newElements, arr.Elements[1:length])
 				return &object.Array{Elements: newElements}
@@ -78,12 +81,126 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"put": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(env.Host().Output(), arg.Inspect())
 			}
 
 			return NULL
 		},
 	},
 }
+
+// names of all builtin functions in alphabetical order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// signature and description of each builtin, shown by editors on hover
+var builtinDocs = map[string]string{
+	"len":   "len(value)\n\nReturns the number of characters of a string or of elements of an array.",
+	"first": "first(array)\n\nReturns the first element of an array, or null when it is empty.",
+	"last":  "last(array)\n\nReturns the last element of an array, or null when it is empty.",
+	"rest":  "rest(array)\n\nReturns a new array holding every element but the first, or null when it is empty.",
+	"put":   "put(values...)\n\nPrints each value on its own line and returns null.",
+
+	"assert":       "assert(condition, message?)\n\nFails with an error unless condition is truthy.",
+	"assert_eq":    "assert_eq(actual, expected, message?)\n\nFails with an error unless actual and expected are deeply equal.",
+	"assert_error": "assert_error(fn, message?)\n\nCalls fn without arguments and fails unless it returns an error containing message.",
+
+	"split":       "split(s, sep?)\n\nSplits s around each sep, a string or a regex, around runs of white space without sep and into characters when sep is empty.",
+	"join":        "join(array, sep?)\n\nJoins an array of strings, putting sep between them.",
+	"trim":        "trim(s, chars?)\n\nRemoves white space, or any of chars, from both ends of s.",
+	"trim_start":  "trim_start(s, chars?)\n\nRemoves white space, or any of chars, from the start of s.",
+	"trim_end":    "trim_end(s, chars?)\n\nRemoves white space, or any of chars, from the end of s.",
+	"replace":     "replace(s, old, new, n?)\n\nReplaces the first n occurrences of old in s by new, every one without n.",
+	"contains":    "contains(s, sub)\n\nReports whether sub occurs in s.",
+	"index_of":    "index_of(s, sub)\n\nReturns the position in characters of the first sub in s, or -1.",
+	"starts_with": "starts_with(s, prefix)\n\nReports whether s starts with prefix.",
+	"ends_with":   "ends_with(s, suffix)\n\nReports whether s ends with suffix.",
+	"upper":       "upper(s)\n\nReturns s in upper case.",
+	"lower":       "lower(s)\n\nReturns s in lower case.",
+	"repeat":      "repeat(s, n)\n\nReturns s repeated n times.",
+	"pad_start":   "pad_start(s, width, fill?)\n\nFills s with spaces, or the character fill, at its start up to width characters.",
+	"pad_end":     "pad_end(s, width, fill?)\n\nFills s with spaces, or the character fill, at its end up to width characters.",
+	"chars":       "chars(s)\n\nReturns the characters of s as an array of strings.",
+	"format":      "format(template, values...)\n\nReplaces each {} in template by the next value and {n} by the value at index n, {{ and }} stand for braces.",
+
+	"push":    "push(array, values...)\n\nReturns a new array with values appended.",
+	"pop":     "pop(array)\n\nReturns a new array without the last element, or null when it is empty.",
+	"slice":   "slice(value, start, end?)\n\nReturns the elements of an array, or the characters of a string, from start up to end, negative positions count from the end.",
+	"concat":  "concat(arrays...)\n\nReturns a new array with the elements of every array in turn.",
+	"reverse": "reverse(value)\n\nReturns the elements of an array, or the characters of a string, in reverse order.",
+	"sort":    "sort(array, less?)\n\nReturns the elements in ascending order, less(a, b) tells whether a goes before b for anything but integers and strings.",
+	"map":     "map(array, fn)\n\nReturns a new array of fn called with each element.",
+	"filter":  "filter(array, fn)\n\nReturns a new array of the elements for which fn returns something truthy.",
+	"reduce":  "reduce(array, fn, initial?)\n\nFolds the elements with fn(value so far, element), starting from initial or the first element.",
+	"find":    "find(array, fn)\n\nReturns the first element for which fn returns something truthy, or null.",
+	"any":     "any(array, fn?)\n\nReports whether some element is truthy, or makes fn return something truthy.",
+	"all":     "all(array, fn?)\n\nReports whether every element is truthy, or makes fn return something truthy.",
+	"zip":     "zip(arrays...)\n\nReturns an array of arrays holding the elements at each position, as long as the shortest array.",
+	"range":   "range(start?, end, step?)\n\nReturns the integers from start, 0 by default, up to but not including end, going by step.",
+	"keys":    "keys(hash)\n\nReturns the keys of a hash.",
+	"values":  "values(hash)\n\nReturns the values of a hash.",
+	"entries": "entries(hash)\n\nReturns the [key, value] pairs of a hash.",
+	"has":     "has(hash, key)\n\nReports whether the hash has key.",
+	"delete":  "delete(hash, keys...)\n\nReturns a new hash without keys.",
+	"merge":   "merge(hashes...)\n\nReturns a new hash with the pairs of every hash, later ones win.",
+
+	"abs":        "abs(x)\n\nReturns the absolute value of a number.",
+	"min":        "min(values...)\n\nReturns the smallest number, of the arguments or of the elements of a single array.",
+	"max":        "max(values...)\n\nReturns the largest number, of the arguments or of the elements of a single array.",
+	"pow":        "pow(base, exponent)\n\nRaises base to exponent, an integer for integers and a non negative exponent, a float otherwise.",
+	"sqrt":       "sqrt(x)\n\nReturns the square root of x as a float.",
+	"floor":      "floor(x)\n\nReturns the largest integer not above x.",
+	"ceil":       "ceil(x)\n\nReturns the smallest integer not below x.",
+	"round":      "round(x)\n\nReturns the integer nearest to x, halves round away from zero.",
+	"sin":        "sin(x)\n\nReturns the sine of x radians.",
+	"cos":        "cos(x)\n\nReturns the cosine of x radians.",
+	"tan":        "tan(x)\n\nReturns the tangent of x radians.",
+	"asin":       "asin(x)\n\nReturns the arcsine of x in radians.",
+	"acos":       "acos(x)\n\nReturns the arccosine of x in radians.",
+	"atan":       "atan(x)\natan(y, x)\n\nReturns the arctangent of x in radians, or the angle in radians of the point (x, y).",
+	"exp":        "exp(x)\n\nReturns e raised to x.",
+	"log":        "log(x, base?)\n\nReturns the logarithm of x, the natural one without base.",
+	"clamp":      "clamp(x, low, high)\n\nReturns x limited to the range from low to high.",
+	"gcd":        "gcd(integers...)\n\nReturns the greatest common divisor of the integers.",
+	"int":        "int(value)\n\nTurns a float into an integer, dropping the fraction, or parses a string.",
+	"float":      "float(value)\n\nTurns an integer into a float or parses a string.",
+	"seed":       "seed(n)\n\nRestarts the random numbers from n, so the same seed gives the same numbers.",
+	"rand_int":   "rand_int(low?, high)\n\nReturns a random integer from low, 0 by default, up to but not including high.",
+	"rand_float": "rand_float()\n\nReturns a random float from 0 up to but not including 1.",
+	"shuffle":    "shuffle(array)\n\nReturns a new array with the elements in random order.",
+
+	"json_encode": "json_encode(value, indent?)\n\nReturns value as JSON text, indented by a number of spaces or a string when indent is given. Hash keys must be strings.",
+	"json_decode": "json_decode(s)\n\nReturns the value written in the JSON text s, or an error saying where s is invalid.",
+
+	"read_file":  "read_file(path)\n\nReturns the content of the file at path, which must lie in a directory the host allows.",
+	"write_file": "write_file(path, content)\n\nReplaces the file at path by the string content, creating it when missing, in a directory the host allows writing to.",
+	"list_dir":   "list_dir(path)\n\nReturns the sorted names of the entries of the directory at path.",
+	"exists":     "exists(path)\n\nReturns whether there is a file or directory at path.",
+	"mkdir":      "mkdir(path)\n\nCreates the directory at path and any missing directories above it, in a directory the host allows writing to.",
+
+	"regex":       "regex(pattern)\n\nReturns the regular expression pattern compiled, in the RE2 syntax of Go.",
+	"match":       "match(re, s)\n\nReturns whether the regex re matches anywhere in s.",
+	"find_all":    "find_all(re, s, n?)\n\nReturns the first n matches of re in s, all of them without n.",
+	"captures":    "captures(re, s)\n\nReturns a hash of the named groups of the first match of re in s, or null when there is none.",
+	"replace_all": "replace_all(re, s, replacement)\n\nReplaces every match of re in s by the string replacement, where $1 and ${name} stand for groups, or by what the function replacement returns for the match.",
+
+	"now":         "now()\n\nReturns the current time, by the clock of the host.",
+	"parse_time":  "parse_time(s, layout?, zone?)\n\nReads the time written in s in layout, RFC3339 by default, times without an offset are taken in zone, UTC by default.",
+	"format_time": "format_time(t, layout?)\n\nWrites the time t in layout, RFC3339 by default.",
+	"duration":    "duration(s)\n\nReturns the duration written in s, such as \"1h30m\" or \"-1.5s\".",
+	"in_zone":     "in_zone(t, zone)\n\nReturns the instant t shown in the time zone zone, such as \"Europe/Paris\".",
+}
+
+func BuiltinDoc(name string) (string, bool) {
+	doc, ok := builtinDocs[name]
+	return doc, ok
+}
@@ -0,0 +1,589 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"sort"
+)
+
+/**
+ * the collections library, arrays and hashes are never changed in place,
+ * the builtins return new ones, those taking a function call it through
+ * Apply so it runs as if called from the script
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"push":    push,
+		"pop":     pop,
+		"slice":   slice,
+		"concat":  concat,
+		"reverse": reverse,
+		"sort":    sortBuiltin,
+		"map":     mapBuiltin,
+		"filter":  filter,
+		"reduce":  reduce,
+		"find":    find,
+		"any":     quantifier("any", true),
+		"all":     quantifier("all", false),
+		"zip":     zip,
+		"range":   rangeBuiltin,
+		"keys":    hashProjection("keys", func(p object.HashPair) object.Object { return p.Key }),
+		"values":  hashProjection("values", func(p object.HashPair) object.Object { return p.Value }),
+		"entries": hashProjection("entries", func(p object.HashPair) object.Object { return &object.Array{Elements: []object.Object{p.Key, p.Value}} }),
+		"has":     has,
+		"delete":  deleteBuiltin,
+		"merge":   merge,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+// a new array of the elements of the first argument followed by the others
+func push(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("push", args, 2, -1); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("push", args, 0)
+	if err != nil {
+		return err
+	}
+	if err := checkLength("push", int64(len(arr.Elements)+len(args)-1)); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, 0, len(arr.Elements)+len(args)-1)
+	elements = append(elements, arr.Elements...)
+	return &object.Array{Elements: append(elements, args[1:]...)}
+}
+
+// a new array without the last element, null for an empty one like rest
+func pop(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("pop", args, 1, 1); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("pop", args, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	elements := make([]object.Object, len(arr.Elements)-1)
+	copy(elements, arr.Elements)
+	return &object.Array{Elements: elements}
+}
+
+/**
+ * slice(value, start, end?) takes the elements of an array, or the
+ * characters of a string, from start up to but not including end, negative
+ * positions count from the end and positions outside are clamped
+ */
+func slice(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("slice", args, 2, 3); err != nil {
+		return err
+	}
+
+	var length int
+	var runes []rune
+	switch value := args[0].(type) {
+	case *object.Array:
+		length = len(value.Elements)
+	case *object.String:
+		runes = []rune(value.Value)
+		length = len(runes)
+	default:
+		return newError("first argument to `slice` must be an ARRAY or a STRING, got %s", args[0].Type())
+	}
+
+	start, err := integerArgument("slice", args, 1)
+	if err != nil {
+		return err
+	}
+	end := int64(length)
+	if len(args) == 3 {
+		if end, err = integerArgument("slice", args, 2); err != nil {
+			return err
+		}
+	}
+	from, to := clampIndex(start, length), clampIndex(end, length)
+	if to < from {
+		to = from
+	}
+
+	if arr, ok := args[0].(*object.Array); ok {
+		elements := make([]object.Object, to-from)
+		copy(elements, arr.Elements[from:to])
+		return &object.Array{Elements: elements}
+	}
+	return &object.String{Value: string(runes[from:to])}
+}
+
+// i as a position between 0 and length, counting from the end when negative
+func clampIndex(i int64, length int) int {
+	if i < 0 {
+		i += int64(length)
+	}
+
+	return int(max(0, min(i, int64(length))))
+}
+
+func concat(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("concat", args, 1, -1); err != nil {
+		return err
+	}
+
+	length := int64(0)
+	for i := range args {
+		arr, err := arrayArgument("concat", args, i)
+		if err != nil {
+			return err
+		}
+		length += int64(len(arr.Elements))
+	}
+	if err := checkLength("concat", length); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, 0, length)
+	for _, arg := range args {
+		elements = append(elements, arg.(*object.Array).Elements...)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// the elements of an array or the characters of a string in reverse order
+func reverse(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("reverse", args, 1, 1); err != nil {
+		return err
+	}
+
+	switch value := args[0].(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(value.Elements))
+		for i, element := range value.Elements {
+			elements[len(elements)-1-i] = element
+		}
+		return &object.Array{Elements: elements}
+
+	case *object.String:
+		runes := []rune(value.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &object.String{Value: string(runes)}
+
+	default:
+		return newError("first argument to `reverse` must be an ARRAY or a STRING, got %s", args[0].Type())
+	}
+}
+
+/**
+ * sort(array, less?) returns the elements in ascending order, numbers and
+ * strings compare by themselves while anything else needs less, a function
+ * telling whether its first argument goes before its second, elements less
+ * does not tell apart keep their order
+ */
+func sortBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("sort", args, 1, 2); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("sort", args, 0)
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+
+	if len(args) == 1 {
+		for _, element := range elements {
+			numbers := isNumber(element) && isNumber(elements[0])
+			strings := element.Type() == object.STRING_OBJ && elements[0].Type() == object.STRING_OBJ
+			if !numbers && !strings {
+				return newError("`sort` needs a function to compare %s and %s", elements[0].Type(), element.Type())
+			}
+		}
+
+		sort.SliceStable(elements, func(i, j int) bool {
+			if a, ok := elements[i].(*object.String); ok {
+				return a.Value < elements[j].(*object.String).Value
+			}
+			if a, ok := elements[i].(*object.Integer); ok {
+				if b, ok := elements[j].(*object.Integer); ok {
+					return a.Value < b.Value
+				}
+			}
+			return toFloat(elements[i]) < toFloat(elements[j])
+		})
+		return &object.Array{Elements: elements}
+	}
+
+	less, err := functionArgument("sort", args, 1)
+	if err != nil {
+		return err
+	}
+
+	// the first error stops the calls, the order is of no use after it
+	var failed object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+
+		res := Apply(less, []object.Object{elements[i], elements[j]}, env)
+		b, ok := res.(*object.Boolean)
+		if !ok {
+			failed = res
+			if !isError(res) {
+				failed = newError("function passed to `sort` must return a BOOLEAN, got %s", res.Type())
+			}
+			return false
+		}
+		return b.Value
+	})
+	if failed != nil {
+		return failed
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// call fn with each element of the array given as the first of args, visit
+// gets the element and the result and says whether to go on
+func each(name string, env *object.Environment, args []object.Object, visit func(element, res object.Object) bool) *object.Error {
+	arr, err := arrayArgument(name, args, 0)
+	if err != nil {
+		return err
+	}
+	fn, err := functionArgument(name, args, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, element := range arr.Elements {
+		res := Apply(fn, []object.Object{element}, env)
+		if err, ok := res.(*object.Error); ok {
+			return err
+		}
+		if !visit(element, res) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// a new array of the results of calling fn with each element
+func mapBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("map", args, 2, 2); err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	err := each("map", env, args, func(element, res object.Object) bool {
+		elements = append(elements, res)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// a new array of the elements for which fn returns something truthy
+func filter(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("filter", args, 2, 2); err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	err := each("filter", env, args, func(element, res object.Object) bool {
+		if isTruthy(res) {
+			elements = append(elements, element)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// the first element for which fn returns something truthy, null if none does
+func find(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("find", args, 2, 2); err != nil {
+		return err
+	}
+
+	var found object.Object = NULL
+	err := each("find", env, args, func(element, res object.Object) bool {
+		if isTruthy(res) {
+			found = element
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return found
+}
+
+/**
+ * any and all test whether some or every element is truthy, or makes fn
+ * return something truthy when one is given, they stop at the first
+ * element deciding the answer, which is stop
+ */
+func quantifier(name string, stop bool) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 2); err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			arr, err := arrayArgument(name, args, 0)
+			if err != nil {
+				return err
+			}
+			for _, element := range arr.Elements {
+				if isTruthy(element) == stop {
+					return nativeBoolToBooleanObject(stop)
+				}
+			}
+			return nativeBoolToBooleanObject(!stop)
+		}
+
+		result := !stop
+		err := each(name, env, args, func(element, res object.Object) bool {
+			if isTruthy(res) == stop {
+				result = stop
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		return nativeBoolToBooleanObject(result)
+	}
+}
+
+/**
+ * reduce(array, fn, initial?) folds the elements into one value, calling
+ * fn with the value so far and each element, without initial the first
+ * element is where it starts
+ */
+func reduce(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("reduce", args, 2, 3); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("reduce", args, 0)
+	if err != nil {
+		return err
+	}
+	fn, err := functionArgument("reduce", args, 1)
+	if err != nil {
+		return err
+	}
+
+	elements := arr.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("`reduce` of an empty array needs an initial value")
+		}
+		acc, elements = elements[0], elements[1:]
+	}
+
+	for _, element := range elements {
+		acc = Apply(fn, []object.Object{acc, element}, env)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+// an array holding an array of the elements at each position of the
+// arrays given, as long as the shortest of them
+func zip(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("zip", args, 1, -1); err != nil {
+		return err
+	}
+
+	length := -1
+	for i := range args {
+		arr, err := arrayArgument("zip", args, i)
+		if err != nil {
+			return err
+		}
+		if length == -1 || len(arr.Elements) < length {
+			length = len(arr.Elements)
+		}
+	}
+
+	tuples := make([]object.Object, length)
+	for i := range tuples {
+		tuple := make([]object.Object, len(args))
+		for j, arg := range args {
+			tuple[j] = arg.(*object.Array).Elements[i]
+		}
+		tuples[i] = &object.Array{Elements: tuple}
+	}
+
+	return &object.Array{Elements: tuples}
+}
+
+/**
+ * range(end), range(start, end) and range(start, end, step) give the
+ * integers from start, 0 by default, up to but not including end, going
+ * by step, which is 1 by default and may be negative to count down
+ */
+func rangeBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("range", args, 1, 3); err != nil {
+		return err
+	}
+
+	bounds := make([]int64, len(args))
+	for i := range args {
+		n, err := integerArgument("range", args, i)
+		if err != nil {
+			return err
+		}
+		bounds[i] = n
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError("`range` cannot step by 0")
+	}
+
+	count := uint64(0)
+	if step > 0 && start < end {
+		count = (uint64(end-start)-1)/uint64(step) + 1
+	} else if step < 0 && start > end {
+		count = (uint64(start-end)-1)/uint64(-step) + 1
+	}
+	if count > uint64(maxLength) {
+		return checkLength("range", int64(min(count, 1<<62)))
+	}
+
+	elements := make([]object.Object, count)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: start + int64(i)*step}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// a builtin listing what project takes from each pair of a hash
+func hashProjection(name string, project func(object.HashPair) object.Object) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 1); err != nil {
+			return err
+		}
+		hash, err := hashArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		pairs := hash.Pairs()
+		elements := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			elements[i] = project(pair)
+		}
+
+		return &object.Array{Elements: elements}
+	}
+}
+
+// the hash key of the argument i of the builtin name
+func keyArgument(name string, args []object.Object, i int) (object.Hashable, *object.Error) {
+	key, err := hashKey(args[i])
+	if err != nil {
+		return nil, newError("%s to `%s` is %s", argumentPosition(i), name, err.Message)
+	}
+
+	return key, nil
+}
+
+func has(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("has", args, 2, 2); err != nil {
+		return err
+	}
+	hash, err := hashArgument("has", args, 0)
+	if err != nil {
+		return err
+	}
+	key, err := keyArgument("has", args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, ok := hash.Get(key)
+	return nativeBoolToBooleanObject(ok)
+}
+
+// a new hash without the keys given after it
+func deleteBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("delete", args, 2, -1); err != nil {
+		return err
+	}
+	hash, err := hashArgument("delete", args, 0)
+	if err != nil {
+		return err
+	}
+
+	deleted := &object.Hash{}
+	for i := 1; i < len(args); i++ {
+		key, err := keyArgument("delete", args, i)
+		if err != nil {
+			return err
+		}
+		deleted.Set(key, TRUE)
+	}
+
+	result := &object.Hash{}
+	for _, pair := range hash.Pairs() {
+		key := pair.Key.(object.Hashable)
+		if _, ok := deleted.Get(key); !ok {
+			result.Set(key, pair.Value)
+		}
+	}
+
+	return result
+}
+
+// a new hash with the pairs of every hash given, later ones win, keys stay
+// where they first appeared
+func merge(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("merge", args, 1, -1); err != nil {
+		return err
+	}
+
+	result := &object.Hash{}
+	for i := range args {
+		hash, err := hashArgument("merge", args, i)
+		if err != nil {
+			return err
+		}
+		for _, pair := range hash.Pairs() {
+			result.Set(pair.Key.(object.Hashable), pair.Value)
+		}
+	}
+
+	return result
+}
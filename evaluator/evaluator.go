@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"interpreter/ast"
 	"interpreter/object"
+	"math"
+	"sort"
 )
 
 var (
@@ -13,6 +15,14 @@ var (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if stmt, ok := node.(ast.Statement); ok {
+		if tracer := env.Tracer(); tracer != nil {
+			if err := tracer.Statement(stmt, env); err != nil {
+				return err
+			}
+		}
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -23,6 +33,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -72,8 +85,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.ImportStatement:
+		module := importModule(node.Path.Value, env)
+		if isError(module) {
+			return locate(node, module)
+		}
+		env.Set(node.Name.Value, module)
+
+	case *ast.ImportExpression:
+		path := Eval(node.Path, env)
+		if isError(path) {
+			return path
+		}
+		if path.Type() != object.STRING_OBJ {
+			return newError("import path must be a STRING, got %s", path.Type())
+		}
+		return locate(node, importModule(path.(*object.String).Value, env))
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
@@ -97,7 +130,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Variadic: node.Variadic, Env: env, Body: body}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -105,12 +138,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		positional, named := splitArguments(node.Arguments)
+		args := evalExpressions(positional, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
+		namedArgs, err := evalNamedArguments(named, env)
+		if err != nil {
+			return err
+		}
+
+		tracer := env.Tracer()
+		if tracer == nil {
+			return locateError(node, function, applyFunction(function, args, namedArgs, env))
+		}
 
-		return applyFunction(function, args)
+		tracer.EnterCall(node, function, env)
+		res := applyFunction(function, args, namedArgs, env)
+		tracer.ExitCall(node, function, res)
+		return locateError(node, function, res)
 	}
 
 	return nil
@@ -158,9 +204,15 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 
+	case isTemporal(left) || isTemporal(right):
+		return evalTemporalInfixExpression(operator, left, right)
+
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 
@@ -193,12 +245,19 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	case *object.Duration:
+		if right.Value == math.MinInt64 {
+			return newError("duration out of range: -%s", right.Inspect())
+		}
+		return &object.Duration{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -213,6 +272,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -227,9 +289,66 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// the value of an integer or a float as a float
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
+// a float with another float or an integer, which is turned into a float
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return floatArithmetic(operator, left, right, leftVal+rightVal)
+	case "-":
+		return floatArithmetic(operator, left, right, leftVal-rightVal)
+	case "*":
+		return floatArithmetic(operator, left, right, leftVal*rightVal)
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+		}
+		return floatArithmetic(operator, left, right, leftVal/rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// the result of a float operation, which fails rather than overflow to an
+// infinity
+func floatArithmetic(operator string, left, right object.Object, result float64) object.Object {
+	if math.IsInf(result, 0) {
+		return newError("float overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+	}
+
+	return &object.Float{Value: result}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
+	if tracer, ok := env.Tracer().(object.BranchTracer); ok {
+		tracer.Branch(ie, isTruthy(condition), env)
+	}
+
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -286,6 +405,11 @@ func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.
 		}
 	}
 
+	// an empty block or one ending in a let still has to produce a value
+	if res == nil {
+		return NULL
+	}
+
 	return res
 }
 
@@ -315,10 +439,26 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("Identifier not found: %s", node.Value)
 }
 
+// evaluate a list of expressions, those spread with ... have to be arrays
+// and add their elements to the list
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var res []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				return []object.Object{newError("cannot spread %s, only an ARRAY", evaluated.Type())}
+			}
+			res = append(res, array.Elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -329,31 +469,172 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return res
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// arguments of a call up to the first named one and the named ones, which
+// the parser puts after all the others
+func splitArguments(args []ast.Expression) ([]ast.Expression, []*ast.NamedArgument) {
+	for i, arg := range args {
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named := []*ast.NamedArgument{}
+			for _, arg := range args[i:] {
+				named = append(named, arg.(*ast.NamedArgument))
+			}
+			return args[:i], named
+		}
+	}
+
+	return args, nil
+}
+
+// values of the named arguments by name, nil when there are none
+func evalNamedArguments(args []*ast.NamedArgument, env *object.Environment) (map[string]object.Object, object.Object) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	named := make(map[string]object.Object)
+	for _, arg := range args {
+		evaluated := Eval(arg.Value, env)
+		if isError(evaluated) {
+			return nil, evaluated
+		}
+		named[arg.Name.Value] = evaluated
+	}
+
+	return named, nil
+}
+
+// env is the caller's environment, the function body runs with the caller's
+// host even though its scope encloses the environment it was defined in,
+// named holds the arguments passed by parameter name
+func applyFunction(fn object.Object, args []object.Object, named map[string]object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendedFunctionEnv(fn, args)
+		extendedEnv, err := extendedFunctionEnv(fn, args, named, env.Host())
+		if err != nil {
+			return err
+		}
 		// evaluate body part
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if len(named) != 0 {
+			return newError("builtin functions take no named arguments")
+		}
+		return fn.Fn(env, args...)
 
 	default:
 		return newError("fn not a function: %s", fn.Type())
 	}
 }
 
-func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// give an error returned by a builtin the position of its call, builtins
+// may return the same error every time, so it is copied rather than changed
+func locateError(call *ast.CallExpression, fn object.Object, res object.Object) object.Object {
+	if _, builtin := fn.(*object.Builtin); builtin {
+		return locate(call, res)
+	}
+
+	return res
+}
+
+/**
+ * bind the parameters of fn to args and the named arguments, missing
+ * optional parameters get their default, evaluated in the new environment
+ * so it can refer to the other arguments, and a variadic parameter gets an
+ * array of the positional arguments left over
+ */
+func extendedFunctionEnv(fn *object.Function, args []object.Object, named map[string]object.Object, host *object.Host) (*object.Environment, *object.Error) {
+	min, max := fn.Arity()
+	if (max != -1 && len(args) > max) || (len(named) == 0 && len(args) < min) {
+		return nil, arityError(fn, len(args)+len(named))
+	}
+	if err := checkNamedArguments(fn, len(args), named); err != nil {
+		return nil, err
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
+	env.SetHost(host)
 
+	unbound := []*ast.Identifier{}
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		switch {
+		case fn.Variadic && paramIdx == len(fn.Parameters)-1:
+			rest := []object.Object{}
+			if paramIdx < len(args) {
+				rest = append(rest, args[paramIdx:]...)
+			}
+			env.Set(param.Value, &object.Array{Elements: rest})
+
+		case paramIdx < len(args):
+			env.Set(param.Value, args[paramIdx])
+
+		case named[param.Value] != nil:
+			env.Set(param.Value, named[param.Value])
+
+		default:
+			unbound = append(unbound, param)
+		}
+	}
+
+	for _, param := range unbound {
+		if fn.Defaults[param] == nil {
+			return nil, newError("missing argument %s to %s", param.Value, functionName(fn))
+		}
+
+		value := Eval(fn.Defaults[param], env)
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, value)
 	}
 
-	return env
+	return env, nil
+}
+
+// every named argument has to name a parameter not given positionally
+func checkNamedArguments(fn *object.Function, positional int, named map[string]object.Object) *object.Error {
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		idx := -1
+		for i, param := range fn.Parameters {
+			if param.Value == name {
+				idx = i
+			}
+		}
+
+		switch {
+		case idx == -1:
+			return newError("%s has no parameter named %s", functionName(fn), name)
+		case fn.Variadic && idx == len(fn.Parameters)-1:
+			return newError("rest parameter %s of %s cannot be passed by name", name, functionName(fn))
+		case idx < positional:
+			return newError("argument %s to %s given twice", name, functionName(fn))
+		}
+	}
+
+	return nil
+}
+
+// error for a call of fn with the wrong number of arguments
+func arityError(fn *object.Function, got int) *object.Error {
+	min, max := fn.Arity()
+	return newError("wrong number of arguments to %s got %d, but wanted %s", functionName(fn), got, wanted(min, max))
+}
+
+// how errors refer to fn
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+
+	return "`" + fn.Name + "`"
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -383,6 +664,9 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleIndexExpression(left, index)
+
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -400,17 +684,44 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// obj as a hash key, or the error telling why it cannot be one, an array
+// names the element which cannot be part of a key
+func hashKey(obj object.Object) (object.Hashable, *object.Error) {
+	key, ok := object.Key(obj)
+	if ok {
+		return key, nil
+	}
+
+	if _, ok := obj.(*object.Array); ok {
+		return nil, newError("unusable as hash key: array holding %s", withArticle(unusableElement(obj).Type()))
+	}
+	return nil, newError("unusable as hash key: %s", obj.Type())
+}
+
+// the innermost element of obj which is no hash key
+func unusableElement(obj object.Object) object.Object {
+	if array, ok := obj.(*object.Array); ok {
+		for _, element := range array.Elements {
+			if _, ok := object.Key(element); !ok {
+				return unusableElement(element)
+			}
+		}
+	}
+
+	return obj
+}
+
 // return value corresponding for the input index
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
 	// check if the index is a hashable key
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	key, err := hashKey(index)
+	if err != nil {
+		return err
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	// return NULL object if has no value for the key
 	if !ok {
 		return NULL
@@ -420,27 +731,43 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
+
+	// in source order, so that later keys override earlier ones and spreads
+	for _, keyNode := range node.Keys {
+		if spread, ok := keyNode.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return evaluated
+			}
+
+			from, ok := evaluated.(*object.Hash)
+			if !ok {
+				return newError("cannot spread %s into a hash, only a HASH", evaluated.Type())
+			}
+			for _, pair := range from.Pairs() {
+				hash.Set(pair.Key.(object.Hashable), pair.Value)
+			}
+			continue
+		}
 
-	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unhashedable key: %s", key.Type())
+		hashKey, err := hashKey(key)
+		if err != nil {
+			return err
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
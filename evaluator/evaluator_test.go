@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"interpreter/ast"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -35,6 +42,34 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5", "2.5"},
+		{"-0.5", "-0.5"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"7 / 2.0", "3.5"},
+		{"0.1 * 3", "0.30000000000000004"},
+		{"1.5 < 2", "true"},
+		{"2 == 2.0", "true"},
+		{"2.0 != 2.5", "true"},
+		{"1000000.0 * 1000000.0 * 1000000000.0", "1e+21"},
+		{"0.0000001 * 1", "1e-07"},
+		{"1.0 / 0", "division by zero: 1.0 / 0"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`"a" + 1.5`, "type mismatch: STRING + FLOAT"},
+	}
+
+	for _, test := range tests {
+		if evaluated := testEval(test.input); evaluated.Inspect() != test.expected && !strings.HasSuffix(evaluated.Inspect(), test.expected) {
+			t.Errorf("%s gave %s, but want %s", test.input, evaluated.Inspect(), test.expected)
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -152,6 +187,18 @@ if (10 > 1) {
 			"unknown operator: STRING - STRING"},
 		{`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION"},
+		{"10 / (5 - 5)",
+			"division by zero: 10 / 0"},
+		{"fn(a, b) { a }(1)",
+			"wrong number of arguments to anonymous function got 1, but wanted 2"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)",
+			"wrong number of arguments to `add` got 3, but wanted 2"},
+		{"let greet = fn(name, greeting = \"hi\") { name }; greet()",
+			"wrong number of arguments to `greet` got 0, but wanted 1 to 2"},
+		{"let log = fn(level, ...messages) { level }; log()",
+			"wrong number of arguments to `log` got 0, but wanted at least 1"},
+		{"fn(a = missing) { a }()",
+			"Identifier not found: missing"},
 	}
 
 	for _, test := range tests {
@@ -228,6 +275,83 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = fn(a, b = 2) { a + b }; add(1)", 3},
+		{"let add = fn(a, b = 2) { a + b }; add(1, 5)", 6},
+		{"let add = fn(a, b = a * 10) { a + b }; add(4)", 44},
+		{"let count = fn(...rest) { len(rest) }; count()", 0},
+		{"let count = fn(a, ...rest) { len(rest) }; count(1, 2, 3)", 2},
+		{"let sum = fn(a, b = 10, ...rest) { a + b + len(rest) }; sum(1)", 11},
+		{"let sum = fn(a, b = 10, ...rest) { a + b + len(rest) }; sum(1, 2, 3, 4)", 5},
+	}
+
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestNamedArgumentsAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sub = fn(a, b) { a - b }; sub(b: 1, a: 10)", 9},
+		{"let sub = fn(a, b) { a - b }; sub(10, b: 1)", 9},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 9)", 129},
+		{"let f = fn(a, b = a + 1, c = b + 1) { c }; f(b: 5, a: 1)", 6},
+		{"let f = fn(a, ...rest) { len(rest) }; f(...[1, 2, 3])", 2},
+		{"let add = fn(a, b) { a + b }; let args = [1, 2]; add(...args)", 3},
+		{"let add = fn(a, b) { a + b }; add(...[1], b: 5)", 6},
+		{"len([...[1, 2], 3, ...[], ...[4]])", 4},
+		{"len(...[[1, 2, 3]])", 3},
+		{`let defaults = {"port": 80, "host": "x"}; {...defaults, "port": 8080}["port"]`, 8080},
+		{`let defaults = {"port": 80}; {"port": 8080, ...defaults}["port"]`, 80},
+		{`{"port": 1, "port": 2}["port"]`, 2},
+	}
+
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a) { a }; f(b: 1)", "`f` has no parameter named b"},
+		{"let f = fn(a) { a }; f(1, a: 1)", "argument a to `f` given twice"},
+		{"let f = fn(a, b) { a }; f(b: 1)", "missing argument a to `f`"},
+		{"let f = fn(a, ...rest) { a }; f(a: 1, rest: 2)", "rest parameter rest of `f` cannot be passed by name"},
+		{"let f = fn(a) { a }; f(1, 2, a: 3)", "wrong number of arguments to `f` got 3, but wanted 1"},
+		{"len(value: [])", "builtin functions take no named arguments"},
+		{"len(...1)", "cannot spread INTEGER, only an ARRAY"},
+		{"[...{}]", "cannot spread HASH, only an ARRAY"},
+		{"{...[]}", "cannot spread ARRAY into a hash, only a HASH"},
+	}
+
+	for _, test := range errors {
+		evaluated := testEval(test.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q did not fail, got %T (%+v)", test.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != test.expected {
+			t.Errorf("%q failed with %q, but wanted %q", test.input, errObj.Message, test.expected)
+		}
+	}
+}
+
+func TestFunctionInspect(t *testing.T) {
+	evaluated := testEval("fn(a, b = 2, ...rest) { a }")
+	if evaluated.Inspect() != "fn(a, b = 2, ...rest) { a }" {
+		t.Errorf("function inspected wrong, got %q", evaluated.Inspect())
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
  let newAdder = fn(x) {
@@ -276,8 +400,15 @@ func TestBuiltinFunction(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("añb")`, 3},
+		{`len("añb") - index_of("añb", "b")`, 1},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments got 2, but wanted 1"},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest([1])`, []int64{}},
+		{`rest([])`, nil},
+		{`first([fn(a) {}(0)])`, nil},
+		{`first([fn() { let a = 1 }()])`, nil},
 	}
 
 	for _, test := range tests {
@@ -298,6 +429,709 @@ func TestBuiltinFunction(t *testing.T) {
 			if errObj.Message != expected {
 				t.Errorf("wrong error message, expected %q, but got %q", expected, errObj.Message)
 			}
+
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not an Array object, got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements, expected %d, but got %d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, element := range expected {
+				testIntegerObject(t, array.Elements[i], element)
+			}
+
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// evaluate each input and the source of the value it should give, which
+// have to be deeply equal
+func testLibrary(t *testing.T, tests []struct{ input, expected string }) {
+	t.Helper()
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		expected := testEval(test.expected)
+		if !Equal(evaluated, expected) {
+			t.Errorf("%s gave %s, but want %s", test.input, evaluated.Inspect(), expected.Inspect())
+		}
+	}
+}
+
+// evaluate each input, which has to fail with the expected message
+func testLibraryErrors(t *testing.T, tests []struct{ input, expected string }) {
+	t.Helper()
+	for _, test := range tests {
+		errObj, ok := testEval(test.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", test.input)
+			continue
+		}
+		if errObj.Message != test.expected {
+			t.Errorf("wrong error message for %q, got %q, but want %q", test.input, errObj.Message, test.expected)
+		}
+	}
+}
+
+func TestStringsLibrary(t *testing.T) {
+	testLibrary(t, []struct{ input, expected string }{
+		{`split("a,b,,c", ",")`, `["a", "b", "", "c"]`},
+		{"split(\"  one two\tthree\n \")", `["one", "two", "three"]`},
+		{`split("héllo", "")`, `["h", "é", "l", "l", "o"]`},
+		{`split("", ",")`, `[""]`},
+		{`join(["a", "b", "c"], ", ")`, `"a, b, c"`},
+		{`join([])`, `""`},
+		{"trim(\"  hi \n\")", `"hi"`},
+		{`trim("xxhixx", "x")`, `"hi"`},
+		{`trim_start("  hi  ")`, `"hi  "`},
+		{`trim_end("¡¡hi!!", "!")`, `"¡¡hi"`},
+		{`replace("aaa", "a", "b")`, `"bbb"`},
+		{`replace("aaa", "a", "b", 2)`, `"bba"`},
+		{`replace("aaa", "a", "b", 0)`, `"aaa"`},
+		{`contains("monkey", "key")`, `true`},
+		{`contains("monkey", "donkey")`, `false`},
+		{`index_of("naïve café", "café")`, `6`},
+		{`index_of("abc", "z")`, `-1`},
+		{`starts_with("monkey", "mon")`, `true`},
+		{`ends_with("monkey", "mon")`, `false`},
+		{`upper("ñandú")`, `"ÑANDÚ"`},
+		{`lower("ÀÉÎ")`, `"àéî"`},
+		{`repeat("ab", 3)`, `"ababab"`},
+		{`repeat("ab", 0)`, `""`},
+		{`pad_start("7", 3, "0")`, `"007"`},
+		{`pad_end("né", 4)`, `"né  "`},
+		{`pad_start("long", 2)`, `"long"`},
+		{`pad_end("a", 3, "·")`, `"a··"`},
+		{`chars("añb")`, `["a", "ñ", "b"]`},
+		{`chars("")`, `[]`},
+		// len, index_of, slice and chars all count characters, not bytes
+		{`len("héllo") == 5`, `true`},
+		{`slice("héllo", index_of("héllo", "l"), len("héllo"))`, `"llo"`},
+		{`len(chars("héllo")) == len("héllo")`, `true`},
+		{`format("{} + {} = {}", 1, 2, 3)`, `"1 + 2 = 3"`},
+		{`format("{1} {0} {1}", "a", "b")`, `"b a b"`},
+		{`format("{{}} {}", [1, "x"])`, `"{} [1, x]"`},
+		{`format("{} is {}", "name", "monkey")`, `"name is monkey"`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`split()`, "wrong number of arguments to `split` got 0, but wanted 1 to 2"},
+		{`split(1)`, "first argument to `split` must be a STRING, got INTEGER"},
+		{`split("a", 1)`, "second argument to `split` must be a STRING or REGEX, got INTEGER"},
+		{`join(["a", 1])`, "element 1 of the array passed to `join` must be a STRING, got INTEGER"},
+		{`join("a")`, "first argument to `join` must be an ARRAY, got STRING"},
+		{`trim_end("a", 1)`, "second argument to `trim_end` must be a STRING, got INTEGER"},
+		{`replace("a", "a")`, "wrong number of arguments to `replace` got 2, but wanted 3 to 4"},
+		{`replace("a", "a", "b", "c")`, "fourth argument to `replace` must be an INTEGER, got STRING"},
+		{`contains("a")`, "wrong number of arguments to `contains` got 1, but wanted 2"},
+		{`upper(true)`, "first argument to `upper` must be a STRING, got BOOLEAN"},
+		{`repeat("a", -1)`, "`repeat` cannot repeat a string -1 times"},
+		{`repeat("ab", 9223372036854775807)`, "`repeat` would build 134217730 elements, more than the limit of 67108864"},
+		{`pad_start("a", 3, "ab")`, "`pad_start` pads with a single character, got \"ab\""},
+		{`format("{} {}", 1)`, "placeholder 1 of `format` has no value, got 1 values"},
+		{`format("{}", 1, 2)`, "value 1 passed to `format` is not used by the template"},
+		{`format("{x}", 1)`, "invalid placeholder {x} in the template of `format`"},
+		{`format("{", 1)`, "unclosed { at 0 in the template of `format`"},
+		{`format("}")`, "unmatched } at 0 in the template of `format`"},
+	})
+}
+
+func TestCollectionsLibrary(t *testing.T) {
+	testLibrary(t, []struct{ input, expected string }{
+		{`let a = [1, 2]; let b = push(a, 3, 4); [a, b]`, `[[1, 2], [1, 2, 3, 4]]`},
+		{`pop([1, 2, 3])`, `[1, 2]`},
+		{`pop([])`, `fn() {}()`},
+		{`slice([1, 2, 3, 4], 1, 3)`, `[2, 3]`},
+		{`slice([1, 2, 3, 4], -2)`, `[3, 4]`},
+		{`slice([1, 2, 3], 2, 1)`, `[]`},
+		{`slice([1, 2, 3], -10, 10)`, `[1, 2, 3]`},
+		{`slice("héllo", 1, 4)`, `"éll"`},
+		{`concat([1], [], [2, 3])`, `[1, 2, 3]`},
+		{`reverse([1, 2, 3])`, `[3, 2, 1]`},
+		{`reverse("añb")`, `"bña"`},
+		{`sort([3, 1, 2])`, `[1, 2, 3]`},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{`sort([])`, `[]`},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, `[3, 2, 1]`},
+		{`sort([[2, "a"], [1, "b"], [2, "c"], [1, "d"]], fn(a, b) { a[0] < b[0] })`, `[[1, "b"], [1, "d"], [2, "a"], [2, "c"]]`},
+		{`map([1, 2, 3], fn(x) { x * 2 })`, `[2, 4, 6]`},
+		{`map(["a", "bc"], len)`, `[1, 2]`},
+		{`map([1, 2], fn(x) { if (x > 1) { return x * 10; } x })`, `[1, 20]`},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, `[3, 4]`},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, `10`},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, `0`},
+		{`reduce(["a", "b"], fn(acc, x) { acc + x }, ">")`, `">ab"`},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, `2`},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, `fn() {}()`},
+		{`[any([false, 1]), any([]), all([1, true]), all([])]`, `[true, false, true, true]`},
+		{`[any([1, 2], fn(x) { x > 1 }), all([1, 2], fn(x) { x > 1 })]`, `[true, false]`},
+		{`zip([1, 2, 3], ["a", "b"])`, `[[1, "a"], [2, "b"]]`},
+		{`range(4)`, `[0, 1, 2, 3]`},
+		{`range(2, 5)`, `[2, 3, 4]`},
+		{`range(10, 0, -3)`, `[10, 7, 4, 1]`},
+		{`range(0, 10, 4)`, `[0, 4, 8]`},
+		{`range(5, 1)`, `[]`},
+		{`keys({"b": 1, "a": 2, 3: 3, true: 4})`, `["b", "a", 3, true]`},
+		{`values({"b": 1, "a": 2})`, `[1, 2]`},
+		{`entries({"a": 1})`, `[["a", 1]]`},
+		{`[has({"a": 1}, "a"), has({"a": 1}, "b")]`, `[true, false]`},
+		{`let h = {"a": 1, "b": 2}; [delete(h, "a", "c"), h]`, `[{"b": 2}, {"a": 1, "b": 2}]`},
+		{`merge({"a": 1, "b": 1}, {"b": 2}, {"c": 3})`, `{"a": 1, "b": 2, "c": 3}`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`push([1])`, "wrong number of arguments to `push` got 1, but wanted at least 2"},
+		{`push(1, 2)`, "first argument to `push` must be an ARRAY, got INTEGER"},
+		{`slice(1, 2)`, "first argument to `slice` must be an ARRAY or a STRING, got INTEGER"},
+		{`concat([1], 2)`, "second argument to `concat` must be an ARRAY, got INTEGER"},
+		{`sort([1, "a"])`, "`sort` needs a function to compare INTEGER and STRING"},
+		{`sort([[1]])`, "`sort` needs a function to compare ARRAY and ARRAY"},
+		{`sort([1, 2], fn(a, b) { 1 })`, "function passed to `sort` must return a BOOLEAN, got INTEGER"},
+		{`sort([1, 2], fn(a, b) { a + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], 1)`, "second argument to `map` must be a FUNCTION, got INTEGER"},
+		{`map([1], fn(a, b) { a })`, "wrong number of arguments to anonymous function got 1, but wanted 2"},
+		{`let f = fn(x) { x / 0 }; map([1, 2], f)`, "division by zero: 1 / 0"},
+		{`filter([1], fn(x) { y })`, "Identifier not found: y"},
+		{`reduce([], fn(a, x) { a })`, "`reduce` of an empty array needs an initial value"},
+		{`zip([1], "a")`, "second argument to `zip` must be an ARRAY, got STRING"},
+		{`range(0, 10, 0)`, "`range` cannot step by 0"},
+		{`range(-9223372036854775807, 9223372036854775807)`, "`range` would build 4611686018427387904 elements, more than the limit of 67108864"},
+		{`range("a")`, "first argument to `range` must be an INTEGER, got STRING"},
+		{`keys([1])`, "first argument to `keys` must be a HASH, got ARRAY"},
+		{`has({}, [1, [fn(x) { x }]])`, "second argument to `has` is unusable as hash key: array holding a FUNCTION"},
+		{`delete({}, "a", fn(x) { x })`, "third argument to `delete` is unusable as hash key: FUNCTION"},
+		{`merge({}, [])`, "second argument to `merge` must be a HASH, got ARRAY"},
+	})
+}
+
+func TestMathLibrary(t *testing.T) {
+	testLibrary(t, []struct{ input, expected string }{
+		{`[abs(-3), abs(3), abs(-2.5)]`, `[3, 3, 2.5]`},
+		{`[min(3, 1, 2), max(3, 1.5, 2), min([4, 2.5]), max([-1])]`, `[1, 3, 2.5, -1]`},
+		{`[pow(2, 10), pow(-3, 3), pow(5, 0), pow(2, -1), pow(4, 0.5)]`, `[1024, -27, 1, 0.5, 2.0]`},
+		{`[sqrt(16), sqrt(2.25)]`, `[4.0, 1.5]`},
+		{`[floor(2.7), floor(-2.5), ceil(2.1), ceil(-2.5), round(2.5), round(-2.5), round(2.4), floor(7)]`, `[2, -3, 3, -2, 3, -3, 2, 7]`},
+		{`[sin(0), cos(0), atan(0, -1) == acos(-1), atan(1) * 4 == acos(-1)]`, `[0.0, 1.0, true, true]`},
+		{`[exp(0), log(exp(2)), log(8, 2), log(100, 10)]`, `[1.0, 2.0, 3.0, 2.0]`},
+		{`[clamp(5, 0, 3), clamp(-1, 0, 3), clamp(1.5, 0, 3)]`, `[3, 0, 1.5]`},
+		{`[gcd(12, 18), gcd(-4, 6), gcd(0, 5), gcd(7)]`, `[6, 2, 5, 7]`},
+		{`[int(2.9), int(-2.9), int("42"), int(" -7 "), float(2), float("1.25")]`, `[2, -2, 42, -7, 2.0, 1.25]`},
+		{`sort([2, 0.5, -1, 1.5])`, `[-1, 0.5, 1.5, 2]`},
+		{`seed(1); let xs = map(range(200), fn(i) { rand_int(-2, 3) }); [min(xs), max(xs)]`, `[-2, 2]`},
+		{`seed(1); all(map(range(100), fn(i) { rand_float() }), fn(f) { if (f < 0) { false } else { f < 1 } })`, `true`},
+		{`sort(shuffle(range(20)))`, `range(20)`},
+		{`reduce(shuffle(range(20)), fn(a, x) { a + x })`, `190`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`abs("1")`, "first argument to `abs` must be an INTEGER or FLOAT, got STRING"},
+		{`abs(-9223372036854775807 - 1)`, "`abs` overflows an integer: -9223372036854775808"},
+		{`min()`, "wrong number of arguments to `min` got 0, but wanted at least 1"},
+		{`min([])`, "`min` of an empty array"},
+		{`max([1, "a"])`, "element 1 of the array passed to `max` must be an INTEGER or FLOAT, got STRING"},
+		{`max(1, true)`, "second argument to `max` must be an INTEGER or FLOAT, got BOOLEAN"},
+		{`pow(2, 63)`, "`pow` overflows an integer: pow(2, 63)"},
+		{`pow(-8, 1.0 / 3)`, "`pow` is not defined for -8, 0.3333333333333333"},
+		{`sqrt(-1)`, "`sqrt` is not defined for -1"},
+		{`asin(2)`, "`asin` is not defined for 2"},
+		{`log(0)`, "`log` is only defined for positive numbers, got 0"},
+		{`log(8, 1)`, "`log` needs a positive base other than 1, got 1"},
+		{`round(pow(10.0, 30))`, "`round` cannot turn 1e+30 into an integer"},
+		{`pow(0, -1)`, "division by zero: pow(0, -1)"},
+		{`pow(0.0, -0.5)`, "division by zero: pow(0.0, -0.5)"},
+		{`pow(10.0, 400)`, "`pow` overflows a float for 10.0, 400"},
+		{`exp(1000)`, "`exp` overflows a float for 1000"},
+		{`let big = pow(2.0, 1000); big * big`, "float overflow: 1.0715086071862673e+301 * 1.0715086071862673e+301"},
+		{`let big = pow(2.0, 1023); -big - big`, "float overflow: -8.98846567431158e+307 - 8.98846567431158e+307"},
+		{`pow(2.0, 1023) / 0.5`, "float overflow: 8.98846567431158e+307 / 0.5"},
+		{`float("Inf")`, "`float` cannot parse \"Inf\""},
+		{`float("nan")`, "`float` cannot parse \"nan\""},
+		{`clamp(1, 3, 2)`, "`clamp` needs low to be at most high, got 3 and 2"},
+		{`gcd(1.5)`, "first argument to `gcd` must be an INTEGER, got FLOAT"},
+		{`int("x")`, "`int` cannot parse \"x\""},
+		{`float([])`, "first argument to `float` must be an INTEGER, FLOAT or STRING, got ARRAY"},
+		{`rand_int(0)`, "`rand_int` needs a positive bound, got 0"},
+		{`rand_int(5, 5)`, "`rand_int` needs low to be below high, got 5 and 5"},
+		{`rand_float(1)`, "wrong number of arguments to `rand_float` got 1, but wanted 0"},
+		{`shuffle("abc")`, "first argument to `shuffle` must be an ARRAY, got STRING"},
+	})
+}
+
+func TestSeededRandomIsReproducible(t *testing.T) {
+	input := `seed(42); [rand_int(1000000), rand_int(-5, 5), rand_float(), shuffle(range(10))]`
+	first := testEval(input).Inspect()
+	for i := 0; i < 3; i++ {
+		if again := testEval(input).Inspect(); again != first {
+			t.Fatalf("the same seed gave %s and then %s", first, again)
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Random: object.NewRandom(42)})
+	program := parser.New(lexer.New(`[rand_int(1000000), rand_int(-5, 5), rand_float(), shuffle(range(10))]`)).ParseProgram()
+	if fromHost := Eval(program, env).Inspect(); fromHost != first {
+		t.Errorf("a host seeded with 42 gave %s, but seed(42) gave %s", fromHost, first)
+	}
+}
+
+func TestJSONEncode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_encode({"b": [1, 2.5, true, first([])], "a": "x", "c": {}})`, `{"b":[1,2.5,true,null],"a":"x","c":{}}`},
+		{`json_encode([1.0, 0.000001, pow(10.0, 21), -0.5, []])`, `[1.0,0.000001,1e+21,-0.5,[]]`},
+		{"json_encode(\"a\tb\\c\n\")", `"a\tb\\c\n"`},
+		{`json_encode("ünï")`, `"ünï"`},
+		{`json_encode({"a": [1, {"b": 2}], "c": []}, 2)`, "{\n  \"a\": [\n    1,\n    {\n      \"b\": 2\n    }\n  ],\n  \"c\": []\n}"},
+		{"json_encode([1], \"\t\")", "[\n\t1\n]"},
+		{`json_encode({"z": 1, "y": 2, "x": 3})`, `{"z":1,"y":2,"x":3}`},
+	}
+
+	for _, test := range tests {
+		evaluated := testEval(test.input)
+		s, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s gave %s, but want a STRING", test.input, evaluated.Inspect())
+			continue
+		}
+		if s.Value != test.expected {
+			t.Errorf("%s gave %q, but want %q", test.input, s.Value, test.expected)
+		}
+	}
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`json_encode(fn(x) { x })`, "`json_encode` cannot encode FUNCTION"},
+		{`json_encode([len])`, "`json_encode` cannot encode BUILTIN"},
+		{`json_encode({1: 2})`, "`json_encode` needs hash keys to be STRINGs, got INTEGER"},
+		{`json_encode(1.0 / 0)`, "division by zero: 1.0 / 0"},
+		{`json_encode(1, -1)`, "`json_encode` indents by 0 to 16 spaces, got -1"},
+		{`json_encode(1, true)`, "second argument to `json_encode` must be an INTEGER or STRING, got BOOLEAN"},
+	})
+}
+
+func TestJSONDecode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": [1, 2.5, true, false, null], "a": "x", "c": {}}`, `{"a": "x", "b": [1, 2.5, true, false, first([])], "c": {}}`},
+		{" \n[ -0, 1e2, 2E-1, 12345678901234567890 ] \t", `[0, 100.0, 0.2, 12345678901234567890.0]`},
+		{`"c\/d\n\u00e9\ud83d\ude00"`, "\"c/d\n\u00e9\U0001F600\""},
+		{`"\ud83d"`, "\"\uFFFD\""},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`},
+		{`[[[]]]`, `[[[]]]`},
+	}
+
+	for _, test := range tests {
+		env := object.NewEnvironment()
+		env.Set("text", &object.String{Value: test.input})
+		evaluated := Eval(parser.New(lexer.New(`json_decode(text)`)).ParseProgram(), env)
+		expected := testEval(test.expected)
+		if !Equal(evaluated, expected) {
+			t.Errorf("json_decode(%q) gave %s, but want %s", test.input, evaluated.Inspect(), expected.Inspect())
+		}
+	}
+
+	// monkey strings cannot hold quotes, so these are compared here
+	env := object.NewEnvironment()
+	env.Set("text", &object.String{Value: `"say \"hi\" \\ bye"`})
+	if s, ok := Eval(parser.New(lexer.New(`json_decode(text)`)).ParseProgram(), env).(*object.String); !ok || s.Value != `say "hi" \ bye` {
+		t.Errorf("json_decode does not unescape quotes and backslashes, got %v", s)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{``, "`json_decode` found invalid JSON at 1:1: expected a value, got end of input"},
+		{`{"a": 1,}`, "`json_decode` found invalid JSON at 1:9: expected a string key, got '}'"},
+		{"[1,\n 2\n 3]", "`json_decode` found invalid JSON at 3:2: expected , or ] after an array element, got '3'"},
+		{`{"a" 1}`, "`json_decode` found invalid JSON at 1:6: expected : after a key, got '1'"},
+		{`{"é": tru}`, "`json_decode` found invalid JSON at 1:7: expected a value, got 't'"},
+		{`"abc`, "`json_decode` found invalid JSON at 1:5: unterminated string"},
+		{`"a\qb"`, "`json_decode` found invalid JSON at 1:4: invalid escape character 'q' in a string"},
+		{`"\u12x4"`, "`json_decode` found invalid JSON at 1:2: invalid escape \\u12x4 in a string"},
+		{"\"a\tb\"", "`json_decode` found invalid JSON at 1:3: control character '\\t' in a string"},
+		{`01`, "`json_decode` found invalid JSON at 1:2: unexpected '1' after the value"},
+		{`-`, "`json_decode` found invalid JSON at 1:2: expected a digit, got end of input"},
+		{`1.e5`, "`json_decode` found invalid JSON at 1:3: expected a digit after the decimal point, got 'e'"},
+		{`1e999`, "`json_decode` found invalid JSON at 1:1: number 1e999 is out of range"},
+		{`[1] [2]`, "`json_decode` found invalid JSON at 1:5: unexpected '[' after the value"},
+		{strings.Repeat("[", maxJSONDepth+2), fmt.Sprintf("`json_decode` found invalid JSON at 1:%d: arrays and objects nest deeper than %d", maxJSONDepth+2, maxJSONDepth)},
+	}
+
+	for _, test := range errors {
+		env := object.NewEnvironment()
+		env.Set("text", &object.String{Value: test.input})
+		errObj, ok := Eval(parser.New(lexer.New(`json_decode(text)`)).ParseProgram(), env).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for json_decode(%q)", test.input)
+			continue
+		}
+		if errObj.Message != test.expected {
+			t.Errorf("wrong error message for json_decode(%q), got %q, but want %q", test.input, errObj.Message, test.expected)
+		}
+	}
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`json_decode(1)`, "first argument to `json_decode` must be a STRING, got INTEGER"},
+	})
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	testLibrary(t, []struct{ input, expected string }{
+		{`json_decode(json_encode({"n": [1, -2.5, 1.0], "s": "q: a, b", "t": true, "h": {"x": first([])}}, 2))`, `{"h": {"x": first([])}, "n": [1, -2.5, 1.0], "s": "q: a, b", "t": true}`},
+		{`let v = [pow(10.0, 21), 0.0000001, 1.0 / 3]; json_decode(json_encode(v))`, `[pow(10.0, 21), 0.0000001, 1.0 / 3]`},
+	})
+}
+
+func TestRegexLibrary(t *testing.T) {
+	line := `let line = "2024-05-01 12:00:03 ERROR disk /dev/sda1 is 97% full";`
+	testLibrary(t, []struct{ input, expected string }{
+		{line + `match(regex("ERROR|WARN"), line)`, `true`},
+		{line + `match(regex("^\d{4}-"), "x2024-")`, `false`},
+		{line + `find_all(regex("\d+"), line)`, `["2024", "05", "01", "12", "00", "03", "1", "97"]`},
+		{line + `find_all(regex("\d+"), line, 2)`, `["2024", "05"]`},
+		{`find_all(regex("x"), "abc")`, `[]`},
+		{line + `captures(regex("(?P<date>\S+) (?P<time>\S+) (?P<level>[A-Z]+)"), line)`, `{"date": "2024-05-01", "time": "12:00:03", "level": "ERROR"}`},
+		{`captures(regex("(?P<a>a)|(?P<b>b)"), "b")`, `{"a": first([]), "b": "b"}`},
+		{`captures(regex("(\d)"), "1")`, `{}`},
+		{`captures(regex("\d"), "abc")`, `first([])`},
+		{`replace_all(regex("(\w+)@(\w+)"), "ann@x, bob@y", "$2:$1")`, `"x:ann, y:bob"`},
+		{`replace_all(regex("(?P<n>\d+)"), "a1b22", "<${n}>")`, `"a<1>b<22>"`},
+		{`replace_all(regex("\d+"), "a1b22c", fn(n) { repeat("#", len(n)) })`, `"a#b##c"`},
+		{`replace_all(regex("[aeiou]"), "monkey", upper)`, `"mOnkEy"`},
+		{`replace_all(regex(""), "ab", "-")`, `"-a-b-"`},
+		{`split("a, b;c ,d", regex("\s*[,;]\s*"))`, `["a", "b", "c", "d"]`},
+		{`split("", regex(","))`, `[""]`},
+		{`let re = regex("a+"); [re, regex("a+") == re]`, `[regex("a+"), false]`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`regex("(a")`, "`regex` cannot compile \"(a\": missing closing ) in \"(a\""},
+		{`regex("a{2,1}")`, "`regex` cannot compile \"a{2,1}\": invalid repeat count in \"{2,1}\""},
+		{`regex(1)`, "first argument to `regex` must be a STRING, got INTEGER"},
+		{`match("a", "a")`, "first argument to `match` must be a REGEX, got STRING"},
+		{`find_all(regex("a"), 1)`, "second argument to `find_all` must be a STRING, got INTEGER"},
+		{`replace_all(regex("a"), "a", 1)`, "third argument to `replace_all` must be a STRING or FUNCTION, got INTEGER"},
+		{`replace_all(regex("a"), "a", fn(m) { 1 })`, "the function passed to `replace_all` must return a STRING, got INTEGER"},
+		{`replace_all(regex("a"), "a", fn(m) { m + 1 })`, "type mismatch: STRING + INTEGER"},
+		{`replace_all(regex("a"), "a", fn(a, b) { a })`, "wrong number of arguments to anonymous function got 1, but wanted 2"},
+		{`split("a", 1)`, "second argument to `split` must be a STRING or REGEX, got INTEGER"},
+	})
+}
+
+func TestTimeLibrary(t *testing.T) {
+	noon := `let noon = parse_time("2024-05-01T12:00:00Z");`
+	testLibrary(t, []struct{ input, expected string }{
+		{noon + `noon + duration("90m")`, `parse_time("2024-05-01T13:30:00Z")`},
+		{noon + `[duration("90m") + noon, noon - duration("12h")]`, `[parse_time("2024-05-01T13:30:00Z"), parse_time("2024-05-01T00:00:00Z")]`},
+		{noon + `parse_time("2024-05-02T00:00:00Z") - noon`, `duration("12h")`},
+		{noon + `let later = noon + duration("1ns"); [noon < later, noon > later, noon == in_zone(noon, "Asia/Tokyo"), noon != later]`, `[true, false, true, true]`},
+		{`format_time(parse_time("2024-05-01 22:00:00", "DateTime") + duration("36h"), "DateOnly")`, `"2024-05-03"`},
+		{`format_time(parse_time("01/05/24 9:05PM", "02/01/06 3:04PM"), "Kitchen")`, `"9:05PM"`},
+		{`format_time(in_zone(parse_time("2024-01-15T12:00:00Z"), "Europe/Paris"), "15:04 MST")`, `"13:00 CET"`},
+		{`format_time(in_zone(parse_time("2024-07-15T12:00:00Z"), "Europe/Paris"), "15:04 MST")`, `"14:00 CEST"`},
+		{`let t = parse_time("2024-07-15 09:00", "2006-01-02 15:04", "America/New_York"); [format_time(t), format_time(in_zone(t, "UTC"), "TimeOnly")]`, `["2024-07-15T09:00:00-04:00", "13:00:00"]`},
+		{`format("{}", parse_time("2024-05-01T12:00:00.5+02:00"))`, `"2024-05-01T12:00:00.5+02:00"`},
+		{`[duration("1h") + duration("30m"), duration("1h") - duration("2h"), duration("1h") * 3, 2 * duration("1m"), duration("1h") / 4, duration("1h") * 1.5, 0.5 * duration("1s"), duration("1s") / 4.0, -duration("2s")]`, `[duration("1h30m"), duration("-1h"), duration("3h"), duration("2m"), duration("15m"), duration("1h30m"), duration("500ms"), duration("250ms"), duration("-2s")]`},
+		{`duration("90m") / duration("1h")`, `1.5`},
+		{`format("{}", duration("90m"))`, `"1h30m0s"`},
+		{`[duration("1s") < duration("1m"), duration("60s") == duration("1m"), duration("1h") == 1, duration("1h") != "1h"]`, `[true, true, false, true]`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`parse_time("yesterday")`, "`parse_time` cannot read \"yesterday\" with the layout \"2006-01-02T15:04:05Z07:00\""},
+		{`parse_time("2024-01-01", "DateOnly", "Mars/Base")`, "`parse_time` does not know the time zone \"Mars/Base\""},
+		{`in_zone(now(), "")`, "`in_zone` does not know the time zone \"\""},
+		{`in_zone(1, "UTC")`, "first argument to `in_zone` must be a TIME, got INTEGER"},
+		{`format_time(now(), 1)`, "second argument to `format_time` must be a STRING, got INTEGER"},
+		{`duration("5 minutes")`, "`duration` cannot read \"5 minutes\", write it like \"1h30m\" with the units h, m, s, ms, us and ns"},
+		{`now(1)`, "wrong number of arguments to `now` got 1, but wanted 0"},
+		{`now() + 1`, "type mismatch: TIME + INTEGER"},
+		{`now() + now()`, "unknown operator: TIME + TIME"},
+		{`duration("1s") - now()`, "type mismatch: DURATION - TIME"},
+		{`duration("1s") + 1`, "type mismatch: DURATION + INTEGER"},
+		{`duration("1s") * duration("1s")`, "unknown operator: DURATION * DURATION"},
+		{`duration("1s") / 0`, "division by zero: 1s / 0"},
+		{`duration("1s") / duration("0s")`, "division by zero: 1s / 0s"},
+		{`duration("2562047h") + duration("2562047h")`, "duration out of range: 2562047h0m0s + 2562047h0m0s"},
+		{`duration("2562047h") * 2`, "duration out of range: 2562047h0m0s * 2"},
+		{`duration("2562047h") * 1.5`, "duration out of range: 2562047h0m0s * 1.5"},
+	})
+}
+
+func TestFrozenClock(t *testing.T) {
+	frozen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Clock: func() time.Time { return frozen }})
+
+	input := `let start = now(); [format_time(now()), now() - start, format_time(in_zone(now(), "Asia/Tokyo"), "15:04")]`
+	evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	expected := testEval(`["2024-05-01T12:00:00Z", duration("0s"), "21:00"]`)
+	if !Equal(evaluated, expected) {
+		t.Errorf("a frozen clock gave %s, but want %s", evaluated.Inspect(), expected.Inspect())
+	}
+
+	before := time.Now()
+	if t2, ok := testEval(`now()`).(*object.Time); !ok || t2.Value.Before(before) || t2.Value.After(time.Now()) {
+		t.Errorf("without a clock now() should give the time of the system, got %v", t2)
+	}
+}
+
+// evaluate input with dir and outside bound to directories, files is put on
+// the host as it is
+func evalFiles(files *object.Files, dir, outside, input string) object.Object {
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Files: files})
+	env.Set("dir", &object.String{Value: dir})
+	env.Set("outside", &object.String{Value: outside})
+
+	return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+}
+
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"data/config.txt": "port=80\n",
+		"data/empty.txt":  "",
+		"out/keep.txt":    "",
+	})
+	writeModules(t, outside, map[string]string{"secret.txt": "hidden"})
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "data", "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	files := object.NewFiles(
+		object.Root{Path: dir, Access: object.ReadOnly},
+		object.Root{Path: filepath.Join(dir, "out"), Access: object.ReadWrite},
+	)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read_file(dir + "/data/config.txt")`, "\"port=80\n\""},
+		{`read_file(dir + "/out/../data/empty.txt")`, `""`},
+		{`list_dir(dir + "/data")`, `["config.txt", "empty.txt", "link.txt"]`},
+		{`[exists(dir + "/data/empty.txt"), exists(dir + "/data/none.txt"), exists(dir)]`, `[true, false, true]`},
+		{`write_file(dir + "/out/new.txt", "hello"); read_file(dir + "/out/new.txt")`, `"hello"`},
+		{`write_file(dir + "/out/new.txt", "again"); read_file(dir + "/out/new.txt")`, `"again"`},
+		{`mkdir(dir + "/out/a/b"); mkdir(dir + "/out/a/b"); write_file(dir + "/out/a/b/c.txt", "x"); list_dir(dir + "/out/a")`, `["b"]`},
+	}
+
+	for _, test := range tests {
+		evaluated := evalFiles(files, dir, outside, test.input)
+		expected := testEval(test.expected)
+		if !Equal(evaluated, expected) {
+			t.Errorf("%s gave %s, but want %s", test.input, evaluated.Inspect(), expected.Inspect())
+		}
+	}
+
+	errors := []struct {
+		files    *object.Files
+		input    string
+		expected string
+	}{
+		{nil, `read_file(dir + "/data/config.txt")`, "`read_file` failed: file access is disabled"},
+		{object.NewFiles(), `exists(dir)`, "`exists` failed: file access is disabled"},
+		{files, `read_file(outside + "/secret.txt")`, "`read_file` failed: OUTSIDE/secret.txt is outside the allowed directories"},
+		{files, `read_file(dir + "/out/../../x")`, "`read_file` failed: DIR/out/../../x is outside the allowed directories"},
+		{files, `read_file(dir + "/data/link.txt")`, "`read_file` failed: DIR/data/link.txt: path escapes from parent"},
+		{files, `write_file(dir + "/data/config.txt", "x")`, "`write_file` failed: DIR/data/config.txt is in a read-only directory"},
+		{files, `mkdir(dir + "/data/new")`, "`mkdir` failed: DIR/data/new is in a read-only directory"},
+		{files, `read_file(dir + "/data/none.txt")`, "`read_file` failed: DIR/data/none.txt: no such file or directory"},
+		{files, `read_file(dir + "/data")`, "`read_file` failed: DIR/data is a directory"},
+		{files, `list_dir(dir + "/data/empty.txt")`, "`list_dir` failed: DIR/data/empty.txt: not a directory"},
+		{files, `write_file(dir + "/out/missing/x.txt", "x")`, "`write_file` failed: DIR/out/missing/x.txt: no such file or directory"},
+		{files, `write_file(dir + "/out/x.txt", 1)`, "second argument to `write_file` must be a STRING, got INTEGER"},
+		{files, `exists(1)`, "first argument to `exists` must be a STRING, got INTEGER"},
+	}
+
+	for _, test := range errors {
+		expected := strings.NewReplacer("OUTSIDE", outside, "DIR", dir).Replace(test.expected)
+		errObj, ok := evalFiles(test.files, dir, outside, test.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", test.input)
+			continue
+		}
+		if errObj.Message != expected {
+			t.Errorf("wrong error message for %q, got %q, but want %q", test.input, errObj.Message, expected)
+		}
+	}
+}
+
+func TestPutWritesToHostOutput(t *testing.T) {
+	input := `let greet = fn(name) { put("hello " + name, [1, 2]) }; greet("monkey");`
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Out: &out})
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	testNullObject(t, Eval(program, env))
+
+	expected := "hello monkey\n[1, 2]\n"
+	if out.String() != expected {
+		t.Errorf("put wrote %q, but want %q", out.String(), expected)
+	}
+}
+
+// write files, relative to dir, for the modules tests to import
+func writeModules(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// evaluate input as if it was the file main.monkey in dir, searching paths
+func evalModule(dir, input string, out *bytes.Buffer, paths ...string) object.Object {
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Out: out, Modules: object.NewModules(paths...)})
+	env.SetFile(filepath.Join(dir, "main.monkey"))
+
+	return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	lib := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"math.monkey":        `put("loading math"); export let double = fn(x) { x * 2 }; let hidden = 1; export let ten = 10;`,
+		"nested/a.monkey":    `import "b.monkey" as b; export let value = b["value"] + 1;`,
+		"nested/b.monkey":    `export let value = 41;`,
+		"cycle/a.monkey":     `import "b.monkey" as b;`,
+		"cycle/b.monkey":     `import "a.monkey" as a;`,
+		"broken.monkey":      `export let x = 1 + true;`,
+		"unparsable.monkey":  `let = 1;`,
+		"failing.monkey":     `export let x = len(1);`,
+		"closure.monkey":     `let base = 100; export let add = fn(x) { base + x };`,
+		"uses_search.monkey": `import "util.monkey" as util; export let value = util["value"];`,
+	})
+	writeModules(t, lib, map[string]string{
+		"util.monkey": `export let value = 7;`,
+	})
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`import "math.monkey" as math; math["double"](math["ten"])`, 20},
+		{`let math = import("math.monkey"); math["ten"]`, 10},
+		{`let name = "math"; import(name + ".monkey")["ten"]`, 10},
+		{`import "nested/a.monkey" as a; a["value"]`, 42},
+		{`import "closure.monkey" as c; let base = 1; c["add"](1)`, 101},
+		{`import "util.monkey" as util; util["value"]`, 7},
+		{`import "uses_search.monkey" as m; m["value"]`, 7},
+		{`let f = fn() { import("math.monkey") }; f()["ten"]`, 10},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		testIntegerObject(t, evalModule(dir, test.input, &out, lib), test.expected)
+	}
+
+	var out bytes.Buffer
+	evaluated := evalModule(dir, `import "math.monkey" as a; import "math.monkey" as b; let c = import("./math.monkey"); a == b`, &out)
+	testBooleanObject(t, evaluated, true)
+	if out.String() != "loading math\n" {
+		t.Errorf("a module imported three times printed %q, but want it evaluated once", out.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`import "missing.monkey" as m;`, `cannot find module "missing.monkey"`},
+		{`import "math.monkey" as m; m["hidden"]`, "does not export hidden"},
+		{`import "cycle/a.monkey" as a;`, "import cycle: " + filepath.Join(dir, "cycle/a.monkey") + " -> " + filepath.Join(dir, "cycle/b.monkey") + " -> " + filepath.Join(dir, "cycle/a.monkey")},
+		{`import "broken.monkey" as m;`, "error in " + filepath.Join(dir, "broken.monkey") + ": type mismatch"},
+		{`import "failing.monkey" as m;`, "error in " + filepath.Join(dir, "failing.monkey") + ":1:16: argument to `len` not supported"},
+		{`import "unparsable.monkey" as m;`, "unparsable.monkey:1:5"},
+		{`import(1)`, "import path must be a STRING, got INTEGER"},
+	}
+
+	for _, test := range errors {
+		var out bytes.Buffer
+		errObj, ok := evalModule(dir, test.input, &out).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", test.input)
+			continue
+		}
+		if !strings.Contains(errObj.Message, test.expected) {
+			t.Errorf("wrong error message for %q, got %q, but want it to contain %q", test.input, errObj.Message, test.expected)
+		}
+	}
+}
+
+// recordingTracer notes every event and stops the evaluation at stopLine
+type recordingTracer struct {
+	events   []string
+	stopLine int
+}
+
+func (r *recordingTracer) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	line := ast.Start(stmt).Line
+	if line == r.stopLine {
+		return &object.Error{Message: "stopped"}
+	}
+
+	r.events = append(r.events, fmt.Sprintf("stmt %d", line))
+	return nil
+}
+
+func (r *recordingTracer) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {
+	r.events = append(r.events, "call "+call.Function.String())
+}
+
+func (r *recordingTracer) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object) {
+	r.events = append(r.events, "return "+result.Inspect())
+}
+
+func TestTracer(t *testing.T) {
+	input := `let double = fn(x) {
+ x * 2
+};
+let a = double(2);
+double(a)`
+
+	tests := []struct {
+		stopLine int
+		expected []string
+		result   string
+	}{
+		{0, []string{"stmt 1", "stmt 4", "call double", "stmt 2", "return 4", "stmt 5", "call double", "stmt 2", "return 8"}, "8"},
+		{5, []string{"stmt 1", "stmt 4", "call double", "stmt 2", "return 4"}, "ERROR: stopped"},
+		{2, []string{"stmt 1", "stmt 4", "call double", "return ERROR: stopped"}, "ERROR: stopped"},
+	}
+
+	for _, test := range tests {
+		tracer := &recordingTracer{stopLine: test.stopLine}
+		env := object.NewEnvironment()
+		env.SetHost(&object.Host{Tracer: tracer})
+
+		result := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		if result.Inspect() != test.result {
+			t.Errorf("stopping at %d: result got %s, but want %s", test.stopLine, result.Inspect(), test.result)
+		}
+
+		if strings.Join(tracer.events, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("stopping at %d: events got %q, but want %q", test.stopLine, tracer.events, test.expected)
 		}
 	}
 }
@@ -373,21 +1207,21 @@ false: 6
 		t.Errorf("evaluated object is not a Hash object got %T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := map[object.Hashable]int64{
+		&object.String{Value: "one"}:   1,
+		&object.String{Value: "two"}:   2,
+		&object.String{Value: "three"}: 3,
+		&object.Integer{Value: 4}:      4,
+		TRUE:                           5,
+		FALSE:                          6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Errorf("evaluating result has wrong number of pairs got %d, but want %d", len(result.Pairs), len(expected))
+	if result.Len() != len(expected) {
+		t.Errorf("evaluating result has wrong number of pairs got %d, but want %d", result.Len(), len(expected))
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no corresponding pairs for given key, got %T", expectedKey)
 		}
@@ -396,6 +1230,51 @@ false: 6
 	}
 }
 
+// Equal ignores the order of keys, so the order is checked on the printed hash
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, `{b: 1, a: 2, 3: 3, true: 4}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{a: 3, b: 2}`},
+		{`let h = {"z": 1}; {"y": 2, ...h, "x": 3, "z": 4}`, `{y: 2, z: 4, x: 3}`},
+		{`keys({"c": 1, "a": 2, "b": 3})`, `[c, a, b]`},
+		{`values({"c": 1, "a": 2, "b": 3})`, `[1, 2, 3]`},
+		{`entries({"c": 1, "a": 2})`, `[[c, 1], [a, 2]]`},
+		{`delete({"c": 1, "a": 2, "b": 3}, "a")`, `{c: 1, b: 3}`},
+		{`merge({"c": 1, "a": 2}, {"b": 3, "c": 4})`, `{c: 4, a: 2, b: 3}`},
+		{`json_encode({"c": 1, "a": {"z": 2, "y": 3}})`, `{"c":1,"a":{"z":2,"y":3}}`},
+		{`json_decode(json_encode({"z": 1, "a": 2, "m": 3}))`, `{z: 1, a: 2, m: 3}`},
+		{`captures(regex("(?P<year>[0-9]+)-(?P<month>[0-9]+)"), "2024-05")`, `{year: 2024, month: 05}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %s, but want %s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestCompositeHashKeys(t *testing.T) {
+	testLibrary(t, []struct{ input, expected string }{
+		{`{[1, 2]: "a"}[[1, 2]]`, `"a"`},
+		{`{[1, 2]: "a"}[[2, 1]]`, `first([])`},
+		{`let grid = {[0, 0]: "x", [0, 1]: "o"}; [grid[[0, 1]], grid[[1, 0]]]`, `["o", first([])]`},
+		{`{["a", [true, 3]]: 1}[["a", [true, 3]]]`, `1`},
+		{`{[]: 1, [[]]: 2}[[[]]]`, `2`},
+		{`keys({[1, "a"]: 1, [1, "a"]: 2, [1]: 3})`, `[[1, "a"], [1]]`},
+		{`[has({[1]: 1}, [1]), has({[1]: 1}, 1), has({[1]: 1}, ["1"])]`, `[true, false, false]`},
+		{`delete({[1, 2]: 1, [3]: 2}, [1, 2])`, `{[3]: 2}`},
+	})
+
+	testLibraryErrors(t, []struct{ input, expected string }{
+		{`{[1, fn(x) { x }]: 1}`, "unusable as hash key: array holding a FUNCTION"},
+		{`{}[[[1.5]]]`, "unusable as hash key: array holding a FLOAT"},
+	})
+}
+
 func TestHashedIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -474,3 +1353,118 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+// branchTracer also records which way if expressions go
+type branchTracer struct {
+	recordingTracer
+}
+
+func (b *branchTracer) Branch(ie *ast.IfExpression, consequence bool, env *object.Environment) {
+	b.events = append(b.events, fmt.Sprintf("if %s %t", ie.Condition.String(), consequence))
+}
+
+func TestBranchTracer(t *testing.T) {
+	input := `if (1 < 2) { 10 }; if (1 > 2) { 10 } else { 20 }; if (false) { 10 }`
+
+	tracer := &branchTracer{}
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Tracer: tracer})
+	Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+
+	branches := []string{}
+	for _, event := range tracer.events {
+		if strings.HasPrefix(event, "if ") {
+			branches = append(branches, event)
+		}
+	}
+
+	expected := []string{"if (1 < 2) true", "if (1 > 2) false", "if false false"}
+	if strings.Join(branches, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("branches got %q, but want %q", branches, expected)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // error message, empty when the assertion holds
+	}{
+		{`assert(1 < 2)`, ""},
+		{`assert(1 > 2)`, "assert failed: got false"},
+		{`assert(false, "ordering")`, "ordering: assert failed: got false"},
+		{`assert_eq([1, {"a": [2]}], [1, {"a": [2]}])`, ""},
+		{`assert_eq({"a": 1, "b": 2}, {"b": 2, "a": 1})`, ""},
+		{`assert_eq([1, 2], [1, 3])`, "assert_eq failed: got [1, 2], but want [1, 3]"},
+		{`assert_eq("1", 1)`, `assert_eq failed: got "1", but want 1`},
+		{`assert_eq({"a": 1}, {"a": 1, "b": 2})`, "assert_eq failed"},
+		{`let f = fn() { 1 }; assert_eq(f, f)`, ""},
+		{`assert_eq(fn() { 1 }, fn() { 1 })`, "assert_eq failed"},
+		{`assert_error(fn() { 1 + true })`, ""},
+		{`assert_error(fn() { 1 + true }, "type mismatch")`, ""},
+		{`assert_error(fn() { 1 })`, "assert_error failed: the function did not fail"},
+		{`assert_error(fn() { 1 + true }, "unknown")`, `assert_error failed: got error "type mismatch: INTEGER + BOOLEAN", but want one containing "unknown"`},
+		{`assert_eq(1, 1.0)`, ""},
+		{`assert_eq([1, 2.5], [1.0, 2.5])`, ""},
+		{`assert_eq(1, 1.5)`, "assert_eq failed: got 1, but want 1.5"},
+		{`assert_error(1)`, "first argument to `assert_error` must be a FUNCTION, got INTEGER"},
+		{`assert_error(fn(x) { x })`, "first argument to `assert_error` must take no arguments, got 1"},
+		{`assert_error(fn() { 1 + true }, 1)`, "second argument to `assert_error` must be a STRING, got INTEGER"},
+		{`assert()`, "wrong number of arguments to `assert` got 0, but wanted 1 to 2"},
+		{`assert_eq(1)`, "wrong number of arguments to `assert_eq` got 1, but wanted 2 to 3"},
+	}
+
+	for _, test := range tests {
+		result := testEval(test.input)
+		err, failed := result.(*object.Error)
+
+		if test.expected == "" {
+			if failed {
+				t.Errorf("%s: unexpected failure %q", test.input, err.Message)
+			}
+			continue
+		}
+
+		if !failed || !strings.Contains(err.Message, test.expected) {
+			t.Errorf("%s: got %s, but want an error containing %q", test.input, result.Inspect(), test.expected)
+		}
+	}
+}
+
+func TestBuiltinErrorsCarryTheirPosition(t *testing.T) {
+	result := testEval("let x = 1;\n  assert_eq(x, 2)")
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error, got %s", result.Inspect())
+	}
+	if err.Line != 2 || err.Column != 3 {
+		t.Errorf("error at %d:%d, but want 2:3", err.Line, err.Column)
+	}
+}
+
+// a builtin may return the same error on every call, each call still gets
+// its own position and the shared error none
+func TestSharedErrorsAreNotChanged(t *testing.T) {
+	shared := &object.Error{Message: "shared"}
+	env := object.NewEnvironment()
+	env.Set("fail", &object.Builtin{Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		return shared
+	}})
+
+	for _, test := range []struct{ input, position string }{
+		{"fail()", "1:1"},
+		{"let x = 1;\n  fail()", "2:3"},
+	} {
+		result := Eval(parser.New(lexer.New(test.input)).ParseProgram(), env)
+		err, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("expected an error, got %s", result.Inspect())
+		}
+		if position := fmt.Sprintf("%d:%d", err.Line, err.Column); position != test.position {
+			t.Errorf("%q: error at %s, but want %s", test.input, position, test.position)
+		}
+	}
+	if shared.Line != 0 || shared.Column != 0 {
+		t.Errorf("the shared error was given the position %d:%d", shared.Line, shared.Column)
+	}
+}
@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"errors"
+	"interpreter/object"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+/**
+ * the file builtins only reach the directories the host opened to scripts
+ * with its Files, a denied or failing operation gives an error naming the
+ * path as the script wrote it
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"read_file":  readFile,
+		"write_file": writeFile,
+		"list_dir":   listDir,
+		"exists":     exists,
+		"mkdir":      mkdir,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+// the root holding the path given as first argument and its name inside the
+// root, the caller closes the root
+func openPath(name string, env *object.Environment, args []object.Object, write bool) (*os.Root, string, string, *object.Error) {
+	path, err := stringArgument(name, args, 0)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	root, rel, openErr := env.Files().Open(path, write)
+	if openErr != nil {
+		return nil, "", "", fileError(name, path, openErr)
+	}
+	return root, rel, path, nil
+}
+
+// errors of the file system name the path the script gave, not the one
+// inside the root
+func fileError(name, path string, err error) *object.Error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return newError("`%s` failed: %s: %s", name, path, pathErr.Err)
+	}
+
+	return newError("`%s` failed: %s", name, err)
+}
+
+func readFile(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("read_file", args, 1, 1); err != nil {
+		return err
+	}
+	root, rel, path, err := openPath("read_file", env, args, false)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	info, statErr := root.Stat(rel)
+	if statErr != nil {
+		return fileError("read_file", path, statErr)
+	}
+	if info.IsDir() {
+		return newError("`read_file` failed: %s is a directory", path)
+	}
+	if err := checkLength("read_file", info.Size()); err != nil {
+		return err
+	}
+
+	content, readErr := root.ReadFile(rel)
+	if readErr != nil {
+		return fileError("read_file", path, readErr)
+	}
+	return &object.String{Value: string(content)}
+}
+
+// write_file(path, content) replaces the file at path, creating it when
+// it does not exist
+func writeFile(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("write_file", args, 2, 2); err != nil {
+		return err
+	}
+	content, err := stringArgument("write_file", args, 1)
+	if err != nil {
+		return err
+	}
+	root, rel, path, err := openPath("write_file", env, args, true)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err := root.WriteFile(rel, []byte(content), 0o644); err != nil {
+		return fileError("write_file", path, err)
+	}
+	return NULL
+}
+
+// the names of the entries of a directory, sorted
+func listDir(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("list_dir", args, 1, 1); err != nil {
+		return err
+	}
+	root, rel, path, err := openPath("list_dir", env, args, false)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	dir, openErr := root.Open(rel)
+	if openErr != nil {
+		return fileError("list_dir", path, openErr)
+	}
+	defer dir.Close()
+
+	names, readErr := dir.Readdirnames(-1)
+	if readErr != nil {
+		return fileError("list_dir", path, readErr)
+	}
+	sort.Strings(names)
+
+	return stringArray(names)
+}
+
+// whether there is a file or directory at path, asking about a path outside
+// the allowed directories is still an error
+func exists(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("exists", args, 1, 1); err != nil {
+		return err
+	}
+	root, rel, path, err := openPath("exists", env, args, false)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	_, statErr := root.Stat(rel)
+	if errors.Is(statErr, fs.ErrNotExist) {
+		return FALSE
+	}
+	if statErr != nil {
+		return fileError("exists", path, statErr)
+	}
+	return TRUE
+}
+
+// mkdir(path) creates the directory and any missing ones above it
+func mkdir(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("mkdir", args, 1, 1); err != nil {
+		return err
+	}
+	root, rel, path, err := openPath("mkdir", env, args, true)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err := root.MkdirAll(rel, 0o755); err != nil {
+		return fileError("mkdir", path, err)
+	}
+	return NULL
+}
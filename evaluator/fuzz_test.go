@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	// statements a fuzzed program may run
+	STEP_BUDGET = 10000
+	// calls it may nest, deeper recursion would overflow the Go stack first
+	DEPTH_BUDGET = 200
+	// bytes a string it builds may hold, doubling one in a loop exhausts memory
+	STRING_BUDGET = 1 << 16
+)
+
+var budgetExceeded = &object.Error{Message: "fuzz budget exceeded"}
+
+// budget stops an evaluation running too long, too deep or too big
+type budget struct {
+	steps int
+	depth int
+}
+
+func (b *budget) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	b.steps++
+	if b.steps > STEP_BUDGET || b.depth > DEPTH_BUDGET {
+		return budgetExceeded
+	}
+
+	for _, name := range env.LocalNames() {
+		if s, ok := env.Get(name); ok {
+			if s, ok := s.(*object.String); ok && len(s.Value) > STRING_BUDGET {
+				return budgetExceeded
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *budget) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {
+	b.depth++
+}
+
+func (b *budget) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object) {
+	b.depth--
+}
+
+// seeds are the conformance programs and inputs which used to panic
+func addSeeds(f *testing.F) {
+	programs, _ := filepath.Glob(filepath.Join("..", "conformance", "testdata", "*.monkey"))
+	for _, program := range programs {
+		if src, err := os.ReadFile(program); err == nil {
+			f.Add(string(src))
+		}
+	}
+
+	for _, input := range []string{
+		`let identity = fn(x) { return x; }; identity(5);`,
+		`let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2);`,
+		`if (10 > 1) { if (10 > 1) { return true + false; } return 1; }`,
+		`{"one": 10 - 9, "thr" + "ee": 6 / 2, 4: 4, true: 5}[true]`,
+		`[1, 2, 3][-1]; len("four"); first([]); last([1]);`,
+		`rest([]); rest([1]); rest([1, 2, 3])`,
+		`fn(a, b) { a + b }(1)`,
+		`let f = fn(a, b = a, ...rest) { [a, b, rest] }; f(1); f(1, 2, 3)`,
+		`let f = fn(n) { f(n + 1) }; f(0)`,
+		`let s = fn(x) { s(x + x) }; s("ab")`,
+		`assert_eq([1, {"a": 2}], [1, {"a": 2}]); assert_error(fn() { 1 / 0 })`,
+		`1 / 0`,
+		`let f = fn(a, b = 2) { [a, b] }; f(b: 3, a: 1); f(...[1, 2]); {...{"a": 1}, "b": [...[2]]}`,
+		`import "missing.monkey" as m; let lib = import(1); export let x = lib["x"]`,
+		`sort(map(filter(range(10), fn(x) { x > 2 }), fn(x) { -x }), fn(a, b) { a < b }); reduce(zip([1], [2]), fn(a, p) { a + p[0] }, 0); keys(merge({"a": 1}, delete({1: 2}, 1)))`,
+		`format("{} {1}", join(split(" a b ", ""), "-"), pad_start(repeat("é", 3), 5, "0"), index_of("añb", "b"))`,
+		`seed(1); [1500.25, 0.1 + 2, -2.5 / 0, pow(2, 63), pow(-8, 0.5), round(1.0 / 3 * 9), int("x"), log(0), atan(1, 0), shuffle(range(rand_int(1, 5)))]`,
+		`json_decode(json_encode({"a": [1, 2.5, -0, first([])], "b": {}}, "  ")); json_decode("[1, {}, tru"); json_decode(" [[-1.5e3], 01]")`,
+		`read_file("/etc/passwd"); write_file("x", "y"); list_dir("."); exists(""); mkdir("a/b")`,
+		`let re = regex("(?P<k>\w+)=(\d*)"); [captures(re, "a=1"), find_all(re, "a= b=2", 1), replace_all(re, "x=1", fn(m) { m + m }), replace_all(re, "", "$2${k}"), split("a=1", re), regex("(")]`,
+		`let t = parse_time("2024-03-30 23:30", "2006-01-02 15:04", "Europe/Berlin"); [t + duration("3h") - t, in_zone(t, "Asia/Tokyo") == t, format_time(t, "Mon MST"), duration("2562047h") * 2, -duration("1s") / 0.5, now() - now()]`,
+		`let h = {"b": 1, "a": 2, "b": 3, ...{"c": 4, "a": 5}}; [keys(h), delete(h, "b", "x"), merge(h, {"d": 6, "b": 7}), json_encode(h)]`,
+		`let g = {[0, [1, "a"]]: 1, [0, [1, "a"]]: 2, []: 3}; [g[[0, [1, "a"]]], g[[[]]], has(g, [true]), delete(g, []), {[first([])]: 1}]`,
+	} {
+		f.Add(input)
+	}
+}
+
+/**
+ * FuzzEval evaluates arbitrary programs which parse, under a budget of
+ * statements, call depth and string size, the evaluator must not panic
+ */
+func FuzzEval(f *testing.F) {
+	addSeeds(f)
+	// a single call of a builtin like repeat should not build more than
+	// the budget allows either, the tests run after it keep the usual limit
+	old := maxLength
+	maxLength = STRING_BUDGET
+	f.Cleanup(func() { maxLength = old })
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		env := object.NewEnvironment()
+		env.SetHost(&object.Host{Out: io.Discard, Tracer: &budget{}})
+		Eval(program, env)
+	})
+}
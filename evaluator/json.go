@@ -0,0 +1,491 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/object"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/**
+ * json_encode and json_decode map hashes, arrays, strings, numbers, booleans
+ * and null to and from JSON, hash keys are written in the order of the hash
+ * and read in the order of the text
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"json_encode": jsonEncode,
+		"json_decode": jsonDecode,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+// how deep arrays and hashes may nest in a document passed to json_decode
+const maxJSONDepth = 10000
+
+// json_encode(value, indent?), indent is a number of spaces or the string to
+// indent with, the text is written on one line without it
+func jsonEncode(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("json_encode", args, 1, 2); err != nil {
+		return err
+	}
+
+	e := &jsonEncoder{}
+	if len(args) == 2 {
+		switch indent := args[1].(type) {
+		case *object.Integer:
+			if indent.Value < 0 || indent.Value > 16 {
+				return newError("`json_encode` indents by 0 to 16 spaces, got %d", indent.Value)
+			}
+			e.indent = strings.Repeat(" ", int(indent.Value))
+		case *object.String:
+			e.indent = indent.Value
+		default:
+			return argumentError("json_encode", 1, "INTEGER or STRING", args[1])
+		}
+	}
+
+	if err := e.encode(args[0], 0); err != nil {
+		return err
+	}
+	return &object.String{Value: e.out.String()}
+}
+
+type jsonEncoder struct {
+	out    strings.Builder
+	indent string
+}
+
+func (e *jsonEncoder) encode(value object.Object, depth int) *object.Error {
+	switch value := value.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(value.Value))
+
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(value.Value, 10))
+
+	case *object.Float:
+		if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+			return newError("`json_encode` cannot encode %s", value.Inspect())
+		}
+		e.out.WriteString(value.Inspect())
+
+	case *object.String:
+		writeJSONString(&e.out, value.Value)
+
+	case *object.Array:
+		e.out.WriteByte('[')
+		for i, element := range value.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(element, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(value.Elements) > 0 {
+			e.newline(depth)
+		}
+		e.out.WriteByte(']')
+
+	case *object.Hash:
+		pairs := value.Pairs()
+		e.out.WriteByte('{')
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("`json_encode` needs hash keys to be STRINGs, got %s", pair.Key.Type())
+			}
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			writeJSONString(&e.out, key.Value)
+			e.out.WriteByte(':')
+			if e.indent != "" {
+				e.out.WriteByte(' ')
+			}
+			if err := e.encode(pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(pairs) > 0 {
+			e.newline(depth)
+		}
+		e.out.WriteByte('}')
+
+	default:
+		return newError("`json_encode` cannot encode %s", value.Type())
+	}
+
+	return checkLength("json_encode", int64(e.out.Len()))
+}
+
+// start a new line indented for depth, when the encoder indents
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.out.WriteByte('\n')
+	for range depth {
+		e.out.WriteString(e.indent)
+	}
+}
+
+func writeJSONString(out *strings.Builder, s string) {
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(out, `\u%04x`, r)
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+}
+
+// json_decode(s), the value written in the JSON document s, errors say where
+// in s the document went wrong as line:column
+func jsonDecode(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("json_decode", args, 1, 1); err != nil {
+		return err
+	}
+	s, err := stringArgument("json_decode", args, 0)
+	if err != nil {
+		return err
+	}
+
+	d := &jsonDecoder{src: s}
+	d.skipSpace()
+	value := d.value(0)
+	if d.err != nil {
+		return d.err
+	}
+	d.skipSpace()
+	if d.pos < len(d.src) {
+		return d.fail("unexpected %s after the value", d.describe())
+	}
+
+	return value
+}
+
+type jsonDecoder struct {
+	src string
+	pos int
+	err *object.Error
+}
+
+// the error for the current position, counted in lines and characters
+func (d *jsonDecoder) fail(format string, a ...any) object.Object {
+	before := d.src[:d.pos]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+
+	d.err = newError("`json_decode` found invalid JSON at %d:%d: %s", line, column, fmt.Sprintf(format, a...))
+	return d.err
+}
+
+// the character at the current position, as it goes in an error
+func (d *jsonDecoder) describe() string {
+	if d.pos >= len(d.src) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(d.src[d.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (d *jsonDecoder) skipSpace() {
+	for d.pos < len(d.src) && strings.IndexByte(" \t\n\r", d.src[d.pos]) >= 0 {
+		d.pos++
+	}
+}
+
+// read the value at the current position, the space before it is skipped
+func (d *jsonDecoder) value(depth int) object.Object {
+	if depth > maxJSONDepth {
+		return d.fail("arrays and objects nest deeper than %d", maxJSONDepth)
+	}
+	if d.pos >= len(d.src) {
+		return d.fail("expected a value, got end of input")
+	}
+
+	switch c := d.src[d.pos]; {
+	case c == '{':
+		return d.object(depth)
+	case c == '[':
+		return d.array(depth)
+	case c == '"':
+		s, ok := d.string()
+		if !ok {
+			return d.err
+		}
+		return &object.String{Value: s}
+	case c == '-' || '0' <= c && c <= '9':
+		return d.number()
+	case strings.HasPrefix(d.src[d.pos:], "true"):
+		d.pos += len("true")
+		return TRUE
+	case strings.HasPrefix(d.src[d.pos:], "false"):
+		d.pos += len("false")
+		return FALSE
+	case strings.HasPrefix(d.src[d.pos:], "null"):
+		d.pos += len("null")
+		return NULL
+	}
+
+	return d.fail("expected a value, got %s", d.describe())
+}
+
+func (d *jsonDecoder) array(depth int) object.Object {
+	d.pos++
+	elements := []object.Object{}
+
+	d.skipSpace()
+	if d.pos < len(d.src) && d.src[d.pos] == ']' {
+		d.pos++
+		return &object.Array{Elements: elements}
+	}
+
+	for {
+		d.skipSpace()
+		element := d.value(depth + 1)
+		if d.err != nil {
+			return d.err
+		}
+		elements = append(elements, element)
+
+		d.skipSpace()
+		switch {
+		case d.pos < len(d.src) && d.src[d.pos] == ',':
+			d.pos++
+		case d.pos < len(d.src) && d.src[d.pos] == ']':
+			d.pos++
+			return &object.Array{Elements: elements}
+		default:
+			return d.fail("expected , or ] after an array element, got %s", d.describe())
+		}
+	}
+}
+
+// a JSON object becomes a hash with its keys in the order they are written,
+// when a key repeats the last value wins
+func (d *jsonDecoder) object(depth int) object.Object {
+	d.pos++
+	hash := &object.Hash{}
+
+	d.skipSpace()
+	if d.pos < len(d.src) && d.src[d.pos] == '}' {
+		d.pos++
+		return hash
+	}
+
+	for {
+		d.skipSpace()
+		if d.pos >= len(d.src) || d.src[d.pos] != '"' {
+			return d.fail("expected a string key, got %s", d.describe())
+		}
+		s, ok := d.string()
+		if !ok {
+			return d.err
+		}
+		key := &object.String{Value: s}
+
+		d.skipSpace()
+		if d.pos >= len(d.src) || d.src[d.pos] != ':' {
+			return d.fail("expected : after a key, got %s", d.describe())
+		}
+		d.pos++
+
+		d.skipSpace()
+		value := d.value(depth + 1)
+		if d.err != nil {
+			return d.err
+		}
+		hash.Set(key, value)
+
+		d.skipSpace()
+		switch {
+		case d.pos < len(d.src) && d.src[d.pos] == ',':
+			d.pos++
+		case d.pos < len(d.src) && d.src[d.pos] == '}':
+			d.pos++
+			return hash
+		default:
+			return d.fail("expected , or } after a value, got %s", d.describe())
+		}
+	}
+}
+
+// read the string starting at the current quote, false when it is invalid
+func (d *jsonDecoder) string() (string, bool) {
+	d.pos++
+
+	var out strings.Builder
+	for {
+		if d.pos >= len(d.src) {
+			d.fail("unterminated string")
+			return "", false
+		}
+
+		c := d.src[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			return out.String(), true
+
+		case c < 0x20:
+			d.fail("control character %s in a string", d.describe())
+			return "", false
+
+		case c == '\\':
+			if !d.escape(&out) {
+				return "", false
+			}
+
+		default:
+			r, size := utf8.DecodeRuneInString(d.src[d.pos:])
+			out.WriteRune(r)
+			d.pos += size
+		}
+	}
+}
+
+var jsonEscapes = map[byte]string{
+	'"': "\"", '\\': "\\", '/': "/", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t",
+}
+
+// read the escape sequence at the current backslash into out
+func (d *jsonDecoder) escape(out *strings.Builder) bool {
+	if d.pos+1 >= len(d.src) {
+		d.pos++
+		d.fail("unterminated string")
+		return false
+	}
+
+	c := d.src[d.pos+1]
+	if s, ok := jsonEscapes[c]; ok {
+		out.WriteString(s)
+		d.pos += 2
+		return true
+	}
+	if c != 'u' {
+		d.pos++
+		d.fail("invalid escape character %s in a string", d.describe())
+		return false
+	}
+
+	r, ok := d.hex(d.pos + 2)
+	if !ok {
+		return false
+	}
+	d.pos += 6
+
+	// characters outside the basic plane come as a pair of escapes
+	if utf16.IsSurrogate(r) {
+		pair := utf8.RuneError
+		if strings.HasPrefix(d.src[d.pos:], `\u`) {
+			low, ok := d.hex(d.pos + 2)
+			if !ok {
+				return false
+			}
+			if pair = utf16.DecodeRune(r, low); pair != utf8.RuneError {
+				d.pos += 6
+			}
+		}
+		r = pair
+	}
+
+	out.WriteRune(r)
+	return true
+}
+
+// the four hex digits at i
+func (d *jsonDecoder) hex(i int) (rune, bool) {
+	if i+4 > len(d.src) {
+		d.pos = len(d.src)
+		d.fail("unterminated string")
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(d.src[i:i+4], 16, 16)
+	if err != nil {
+		d.fail("invalid escape \\u%s in a string", d.src[i:i+4])
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// numbers without a fraction or exponent become integers, when they fit
+func (d *jsonDecoder) number() object.Object {
+	start := d.pos
+	digits := func() int {
+		n := 0
+		for d.pos < len(d.src) && '0' <= d.src[d.pos] && d.src[d.pos] <= '9' {
+			d.pos++
+			n++
+		}
+		return n
+	}
+
+	if d.src[d.pos] == '-' {
+		d.pos++
+	}
+	if d.pos < len(d.src) && d.src[d.pos] == '0' {
+		d.pos++
+	} else if digits() == 0 {
+		return d.fail("expected a digit, got %s", d.describe())
+	}
+
+	integer := true
+	if d.pos < len(d.src) && d.src[d.pos] == '.' {
+		integer = false
+		d.pos++
+		if digits() == 0 {
+			return d.fail("expected a digit after the decimal point, got %s", d.describe())
+		}
+	}
+	if d.pos < len(d.src) && (d.src[d.pos] == 'e' || d.src[d.pos] == 'E') {
+		integer = false
+		d.pos++
+		if d.pos < len(d.src) && (d.src[d.pos] == '+' || d.src[d.pos] == '-') {
+			d.pos++
+		}
+		if digits() == 0 {
+			return d.fail("expected a digit in the exponent, got %s", d.describe())
+		}
+	}
+
+	text := d.src[start:d.pos]
+	if integer {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return &object.Integer{Value: n}
+		}
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		d.pos = start
+		return d.fail("number %s is out of range", text)
+	}
+	return &object.Float{Value: f}
+}
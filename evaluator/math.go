@@ -0,0 +1,470 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/**
+ * the math library, integers and floats can be mixed, functions whose result
+ * is not a whole number give a float, floor, ceil, round and int turn floats
+ * into integers, a result too large for a float or without a value is an
+ * error rather than an infinity or NaN, the random builtins draw from the
+ * generator of the host so that seed makes a run reproducible
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"abs":        abs,
+		"min":        extreme("min", -1),
+		"max":        extreme("max", 1),
+		"pow":        pow,
+		"sqrt":       floatFunction("sqrt", math.Sqrt),
+		"floor":      rounding("floor", math.Floor),
+		"ceil":       rounding("ceil", math.Ceil),
+		"round":      rounding("round", math.Round),
+		"sin":        floatFunction("sin", math.Sin),
+		"cos":        floatFunction("cos", math.Cos),
+		"tan":        floatFunction("tan", math.Tan),
+		"asin":       floatFunction("asin", math.Asin),
+		"acos":       floatFunction("acos", math.Acos),
+		"atan":       atan,
+		"exp":        floatFunction("exp", math.Exp),
+		"log":        logBuiltin,
+		"clamp":      clamp,
+		"gcd":        gcd,
+		"int":        intBuiltin,
+		"float":      floatBuiltin,
+		"seed":       seed,
+		"rand_int":   randInt,
+		"rand_float": randFloat,
+		"shuffle":    shuffle,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+const NUMBER = "INTEGER or FLOAT"
+
+// an integer or a float
+func numberArgument(name string, args []object.Object, i int) (object.Object, *object.Error) {
+	if !isNumber(args[i]) {
+		return nil, argumentError(name, i, NUMBER, args[i])
+	}
+
+	return args[i], nil
+}
+
+func floatArgument(name string, args []object.Object, i int) (float64, *object.Error) {
+	n, err := numberArgument(name, args, i)
+	if err != nil {
+		return 0, err
+	}
+
+	return toFloat(n), nil
+}
+
+func abs(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("abs", args, 1, 1); err != nil {
+		return err
+	}
+	n, err := numberArgument("abs", args, 0)
+	if err != nil {
+		return err
+	}
+
+	if i, ok := n.(*object.Integer); ok {
+		if i.Value < 0 {
+			if i.Value == math.MinInt64 {
+				return newError("`abs` overflows an integer: %d", i.Value)
+			}
+			return &object.Integer{Value: -i.Value}
+		}
+		return i
+	}
+	return &object.Float{Value: math.Abs(toFloat(n))}
+}
+
+// compare two numbers, -1, 0 or 1 as a is smaller, equal or larger than b
+func compareNumbers(a, b object.Object) int {
+	if a, ok := a.(*object.Integer); ok {
+		if b, ok := b.(*object.Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1
+			case a.Value > b.Value:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// min and max of their arguments, or of the elements of a single array,
+// the first of equal numbers wins, sign tells which end is wanted
+func extreme(name string, sign int) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, -1); err != nil {
+			return err
+		}
+
+		values := args
+		if arr, ok := args[0].(*object.Array); ok && len(args) == 1 {
+			if len(arr.Elements) == 0 {
+				return newError("`%s` of an empty array", name)
+			}
+			values = arr.Elements
+		}
+
+		var best object.Object
+		for i, value := range values {
+			if !isNumber(value) {
+				if len(values) != len(args) {
+					return newError("element %d of the array passed to `%s` must be an %s, got %s", i, name, NUMBER, value.Type())
+				}
+				return argumentError(name, i, NUMBER, value)
+			}
+			if best == nil || compareNumbers(value, best) == sign {
+				best = value
+			}
+		}
+
+		return best
+	}
+}
+
+/**
+ * pow(base, exponent) stays an integer when both are integers and the
+ * exponent is not negative, failing rather than overflowing, and gives a
+ * float otherwise
+ */
+func pow(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("pow", args, 2, 2); err != nil {
+		return err
+	}
+	for i := range args {
+		if _, err := numberArgument("pow", args, i); err != nil {
+			return err
+		}
+	}
+
+	base, baseInt := args[0].(*object.Integer)
+	exponent, exponentInt := args[1].(*object.Integer)
+	if !baseInt || !exponentInt || exponent.Value < 0 {
+		if toFloat(args[0]) == 0 && toFloat(args[1]) < 0 {
+			return newError("division by zero: pow(%s, %s)", args[0].Inspect(), args[1].Inspect())
+		}
+		return floatResult("pow", math.Pow(toFloat(args[0]), toFloat(args[1])), args)
+	}
+
+	// square and multiply, checking every step
+	result, b, ok := int64(1), base.Value, true
+	for e := exponent.Value; e > 0 && ok; e >>= 1 {
+		if e&1 == 1 {
+			result, ok = multiply(result, b)
+		}
+		if e > 1 && ok {
+			b, ok = multiply(b, b)
+		}
+	}
+	if !ok {
+		return newError("`pow` overflows an integer: pow(%d, %d)", base.Value, exponent.Value)
+	}
+
+	return &object.Integer{Value: result}
+}
+
+// a * b unless it does not fit in an int64
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
+
+// a float result of the builtin name for args, which has no answer when it
+// is NaN and none a float can hold when it is infinite
+func floatResult(name string, f float64, args []object.Object) object.Object {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return &object.Float{Value: f}
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.Inspect()
+	}
+	if math.IsNaN(f) {
+		return newError("`%s` is not defined for %s", name, strings.Join(values, ", "))
+	}
+	return newError("`%s` overflows a float for %s", name, strings.Join(values, ", "))
+}
+
+// a builtin applying fn to one number
+func floatFunction(name string, fn func(float64) float64) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 1); err != nil {
+			return err
+		}
+		x, err := floatArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		return floatResult(name, fn(x), args)
+	}
+}
+
+// floor, ceil and round give integers, which pass through unchanged
+func rounding(name string, fn func(float64) float64) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 1); err != nil {
+			return err
+		}
+		n, err := numberArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		if i, ok := n.(*object.Integer); ok {
+			return i
+		}
+		return toInteger(name, fn(toFloat(n)))
+	}
+}
+
+// f, a whole number, as an integer unless it does not fit
+func toInteger(name string, f float64) object.Object {
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return newError("`%s` cannot turn %s into an integer", name, (&object.Float{Value: f}).Inspect())
+	}
+
+	return &object.Integer{Value: int64(f)}
+}
+
+// atan(x) is the arctangent of x, atan(y, x) the angle of the point (x, y)
+func atan(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("atan", args, 1, 2); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return floatFunction("atan", math.Atan)(env, args...)
+	}
+	y, err := floatArgument("atan", args, 0)
+	if err != nil {
+		return err
+	}
+	x, err := floatArgument("atan", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &object.Float{Value: math.Atan2(y, x)}
+}
+
+// log(x, base?), the natural logarithm without base
+func logBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("log", args, 1, 2); err != nil {
+		return err
+	}
+	x, err := floatArgument("log", args, 0)
+	if err != nil {
+		return err
+	}
+	if x <= 0 {
+		return newError("`log` is only defined for positive numbers, got %s", args[0].Inspect())
+	}
+	if len(args) == 1 {
+		return &object.Float{Value: math.Log(x)}
+	}
+
+	base, err := floatArgument("log", args, 1)
+	if err != nil {
+		return err
+	}
+	if base <= 0 || base == 1 {
+		return newError("`log` needs a positive base other than 1, got %s", args[1].Inspect())
+	}
+	return &object.Float{Value: math.Log(x) / math.Log(base)}
+}
+
+// clamp(x, low, high) is x limited to the range from low to high
+func clamp(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("clamp", args, 3, 3); err != nil {
+		return err
+	}
+	for i := range args {
+		if _, err := numberArgument("clamp", args, i); err != nil {
+			return err
+		}
+	}
+
+	x, low, high := args[0], args[1], args[2]
+	if compareNumbers(low, high) > 0 {
+		return newError("`clamp` needs low to be at most high, got %s and %s", low.Inspect(), high.Inspect())
+	}
+
+	switch {
+	case compareNumbers(x, low) < 0:
+		return low
+	case compareNumbers(x, high) > 0:
+		return high
+	}
+	return x
+}
+
+// the greatest common divisor of integers, never negative
+func gcd(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("gcd", args, 1, -1); err != nil {
+		return err
+	}
+
+	result := uint64(0)
+	for i := range args {
+		n, err := integerArgument("gcd", args, i)
+		if err != nil {
+			return err
+		}
+
+		m := uint64(n)
+		if n < 0 {
+			m = -m
+		}
+		for m != 0 {
+			result, m = m, result%m
+		}
+	}
+
+	if result > math.MaxInt64 {
+		return newError("`gcd` overflows an integer: %d", result)
+	}
+	return &object.Integer{Value: int64(result)}
+}
+
+// int(value) drops the fraction of a float or parses a string of digits
+func intBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("int", args, 1, 1); err != nil {
+		return err
+	}
+
+	switch value := args[0].(type) {
+	case *object.Integer:
+		return value
+	case *object.Float:
+		return toInteger("int", math.Trunc(value.Value))
+	case *object.String:
+		n, err := strconv.ParseInt(strings.TrimSpace(value.Value), 10, 64)
+		if err != nil {
+			return newError("`int` cannot parse %s", strconv.Quote(value.Value))
+		}
+		return &object.Integer{Value: n}
+	default:
+		return argumentError("int", 0, "INTEGER, FLOAT or STRING", value)
+	}
+}
+
+// float(value) turns an integer into a float or parses a string
+func floatBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("float", args, 1, 1); err != nil {
+		return err
+	}
+
+	switch value := args[0].(type) {
+	case *object.Integer, *object.Float:
+		return &object.Float{Value: toFloat(value)}
+	case *object.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value.Value), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return newError("`float` cannot parse %s", strconv.Quote(value.Value))
+		}
+		return &object.Float{Value: f}
+	default:
+		return argumentError("float", 0, "INTEGER, FLOAT or STRING", value)
+	}
+}
+
+// seed(n) restarts the random numbers of the evaluation from n
+func seed(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("seed", args, 1, 1); err != nil {
+		return err
+	}
+	n, err := integerArgument("seed", args, 0)
+	if err != nil {
+		return err
+	}
+
+	env.Seed(n)
+	return NULL
+}
+
+// rand_int(n) is a random integer from 0 up to but not including n, and
+// rand_int(low, high) one from low up to but not including high
+func randInt(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("rand_int", args, 1, 2); err != nil {
+		return err
+	}
+
+	bounds := make([]int64, len(args))
+	for i := range args {
+		n, err := integerArgument("rand_int", args, i)
+		if err != nil {
+			return err
+		}
+		bounds[i] = n
+	}
+
+	low, high := int64(0), bounds[0]
+	if len(bounds) == 2 {
+		low, high = bounds[0], bounds[1]
+	}
+	if low >= high {
+		if len(bounds) == 1 {
+			return newError("`rand_int` needs a positive bound, got %d", high)
+		}
+		return newError("`rand_int` needs low to be below high, got %d and %d", low, high)
+	}
+
+	return &object.Integer{Value: low + int64(env.Random().Uint64N(uint64(high)-uint64(low)))}
+}
+
+// a random float from 0 up to but not including 1
+func randFloat(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("rand_float", args, 0, 0); err != nil {
+		return err
+	}
+
+	return &object.Float{Value: env.Random().Float64()}
+}
+
+// a new array with the elements in random order
+func shuffle(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("shuffle", args, 1, 1); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("shuffle", args, 0)
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+	env.Random().Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,141 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * importModule returns the module of the file spec names, evaluating it
+ * the first time, in an environment of its own sharing the host of env, a
+ * relative spec is looked up next to the file env belongs to and then in
+ * each of the search paths
+ */
+func importModule(spec string, env *object.Environment) object.Object {
+	modules := env.Modules()
+
+	path, err := resolveModule(spec, env.File(), modules.Paths)
+	if err != nil {
+		return err
+	}
+
+	if module, ok := modules.Cached(path); ok {
+		return module
+	}
+
+	if cycle := modules.Begin(path); cycle != nil {
+		return newError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	module, err := loadModule(path, env.Host())
+	if err != nil {
+		modules.End(path, nil)
+		return err
+	}
+	modules.End(path, module)
+
+	return module
+}
+
+// absolute path of the file spec names
+func resolveModule(spec, from string, paths []string) (string, *object.Error) {
+	candidates := []string{spec}
+	if !filepath.IsAbs(spec) {
+		dir := "."
+		if from != "" {
+			dir = filepath.Dir(from)
+		}
+
+		candidates = []string{filepath.Join(dir, spec)}
+		for _, p := range paths {
+			candidates = append(candidates, filepath.Join(p, spec))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			abs, err := filepath.Abs(candidate)
+			if err != nil {
+				return "", newError("cannot import %q: %s", spec, err)
+			}
+			return abs, nil
+		}
+	}
+
+	return "", newError("cannot find module %q, looked for %s", spec, strings.Join(candidates, ", "))
+}
+
+// evaluate the file at path and collect what it exports
+func loadModule(path string, host *object.Host) (*object.Module, *object.Error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("cannot import %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if details := p.ErrorDetails(); len(details) != 0 {
+		e := details[0]
+		return nil, newError("cannot import %s: %s:%d:%d: %s", path, path, e.Line, e.Column, e.Message)
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(host)
+	env.SetFile(path)
+
+	if err, ok := Eval(program, env).(*object.Error); ok {
+		return nil, inModule(path, err)
+	}
+
+	module := &object.Module{Path: path, Exports: make(map[string]object.Object)}
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Exported {
+			module.Exports[let.Name.Value], _ = env.Get(let.Name.Value)
+		}
+	}
+
+	return module, nil
+}
+
+// an error raised while evaluating the module at path, the file and the
+// position are moved into the message as they are not the importer's
+func inModule(path string, err *object.Error) *object.Error {
+	if strings.HasPrefix(err.Message, "error in ") {
+		return &object.Error{Message: err.Message}
+	}
+	if err.Line == 0 {
+		return newError("error in %s: %s", path, err.Message)
+	}
+
+	return newError("error in %s:%d:%d: %s", path, err.Line, err.Column, err.Message)
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	m := module.(*object.Module)
+	name := index.(*object.String).Value
+
+	value, ok := m.Exports[name]
+	if !ok {
+		return newError("module %s does not export %s", m.Path, name)
+	}
+
+	return value
+}
+
+// a copy of err with the position of node unless it has one already, the
+// error given may be shared and is left as it is
+func locate(node ast.Node, res object.Object) object.Object {
+	if err, ok := res.(*object.Error); ok && err.Line == 0 {
+		start := ast.Start(node)
+		located := *err
+		located.Line, located.Column = start.Line, start.Column
+		return &located
+	}
+
+	return res
+}
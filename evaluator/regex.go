@@ -0,0 +1,186 @@
+package evaluator
+
+import (
+	"errors"
+	"interpreter/object"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+/**
+ * regular expressions use the RE2 syntax of Go, regex compiles a pattern
+ * once so the other builtins can match it as often as they like, the lexer
+ * has no escapes so a backslash in a pattern is written as it is, "\d+"
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"regex":       regex,
+		"match":       match,
+		"find_all":    findAll,
+		"captures":    captures,
+		"replace_all": replaceAll,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+func regex(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("regex", args, 1, 1); err != nil {
+		return err
+	}
+	pattern, err := stringArgument("regex", args, 0)
+	if err != nil {
+		return err
+	}
+
+	re, compileErr := regexp.Compile(pattern)
+	if compileErr != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(compileErr, &syntaxErr) {
+			return newError("`regex` cannot compile %s: %s in %s", strconv.Quote(pattern), syntaxErr.Code, strconv.Quote(syntaxErr.Expr))
+		}
+		return newError("`regex` cannot compile %s: %s", strconv.Quote(pattern), compileErr)
+	}
+
+	return &object.Regex{Value: re}
+}
+
+// the regex and the string the regex builtin name works on, its first two
+// arguments
+func regexAndString(name string, args []object.Object) (*regexp.Regexp, string, *object.Error) {
+	re, err := regexArgument(name, args, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := stringArgument(name, args, 1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return re, s, nil
+}
+
+// whether the regex matches anywhere in s
+func match(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("match", args, 2, 2); err != nil {
+		return err
+	}
+	re, s, err := regexAndString("match", args)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBooleanObject(re.MatchString(s))
+}
+
+// find_all(re, s, n?) the first n matches in s, all of them without n
+func findAll(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("find_all", args, 2, 3); err != nil {
+		return err
+	}
+	re, s, err := regexAndString("find_all", args)
+	if err != nil {
+		return err
+	}
+	n := int64(-1)
+	if len(args) == 3 {
+		if n, err = integerArgument("find_all", args, 2); err != nil {
+			return err
+		}
+	}
+
+	return stringArray(re.FindAllString(s, int(max(n, -1))))
+}
+
+// the named groups of the first match as a hash, groups which took no part
+// in the match are null, null when the regex does not match at all
+func captures(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("captures", args, 2, 2); err != nil {
+		return err
+	}
+	re, s, err := regexAndString("captures", args)
+	if err != nil {
+		return err
+	}
+
+	found := re.FindStringSubmatchIndex(s)
+	if found == nil {
+		return NULL
+	}
+
+	hash := &object.Hash{}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+
+		key := &object.String{Value: name}
+		var value object.Object = NULL
+		if start, end := found[2*i], found[2*i+1]; start >= 0 {
+			value = &object.String{Value: s[start:end]}
+		}
+		hash.Set(key, value)
+	}
+
+	return hash
+}
+
+/**
+ * replace_all(re, s, replacement) replaces every match in s, a string
+ * replacement may refer to groups as $1 or ${name}, a function is called
+ * with each match and returns the string to put in its place
+ */
+func replaceAll(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("replace_all", args, 3, 3); err != nil {
+		return err
+	}
+	re, s, err := regexAndString("replace_all", args)
+	if err != nil {
+		return err
+	}
+
+	var replacement func(match []int) (string, *object.Error)
+	switch with := args[2].(type) {
+	case *object.String:
+		replacement = func(match []int) (string, *object.Error) {
+			return string(re.ExpandString(nil, with.Value, s, match)), nil
+		}
+
+	case *object.Function, *object.Builtin:
+		replacement = func(match []int) (string, *object.Error) {
+			res := Apply(with, []object.Object{&object.String{Value: s[match[0]:match[1]]}}, env)
+			switch res := res.(type) {
+			case *object.Error:
+				return "", res
+			case *object.String:
+				return res.Value, nil
+			default:
+				return "", newError("the function passed to `replace_all` must return a STRING, got %s", res.Type())
+			}
+		}
+
+	default:
+		return argumentError("replace_all", 2, "STRING or FUNCTION", args[2])
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(s, -1) {
+		with, err := replacement(match)
+		if err != nil {
+			return err
+		}
+
+		out.WriteString(s[last:match[0]])
+		out.WriteString(with)
+		last = match[1]
+		if err := checkLength("replace_all", int64(out.Len()+len(s)-last)); err != nil {
+			return err
+		}
+	}
+	out.WriteString(s[last:])
+
+	return &object.String{Value: out.String()}
+}
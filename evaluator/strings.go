@@ -0,0 +1,391 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/**
+ * the strings library, positions and lengths are counted in characters
+ * (unicode code points) rather than bytes, so a string is never cut in the
+ * middle of a character
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"split":       split,
+		"join":        join,
+		"trim":        trim("trim", strings.Trim, strings.TrimFunc),
+		"trim_start":  trim("trim_start", strings.TrimLeft, strings.TrimLeftFunc),
+		"trim_end":    trim("trim_end", strings.TrimRight, strings.TrimRightFunc),
+		"replace":     replace,
+		"contains":    contains,
+		"index_of":    indexOf,
+		"starts_with": startsWith,
+		"ends_with":   endsWith,
+		"upper":       upper,
+		"lower":       lower,
+		"repeat":      repeat,
+		"pad_start":   pad(true),
+		"pad_end":     pad(false),
+		"chars":       chars,
+		"format":      format,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+// split s around each sep, around runs of white space when sep is left out
+// and into characters when it is empty, sep may also be a regex to split
+// around each of its matches
+func split(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("split", args, 1, 2); err != nil {
+		return err
+	}
+	s, err := stringArgument("split", args, 0)
+	if err != nil {
+		return err
+	}
+
+	var parts []string
+	if len(args) == 1 {
+		parts = strings.Fields(s)
+	} else {
+		switch sep := args[1].(type) {
+		case *object.String:
+			parts = strings.Split(s, sep.Value)
+		case *object.Regex:
+			parts = sep.Value.Split(s, -1)
+		default:
+			return argumentError("split", 1, "STRING or REGEX", args[1])
+		}
+	}
+
+	return stringArray(parts)
+}
+
+func join(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("join", args, 1, 2); err != nil {
+		return err
+	}
+	arr, err := arrayArgument("join", args, 0)
+	if err != nil {
+		return err
+	}
+	sep := ""
+	if len(args) == 2 {
+		if sep, err = stringArgument("join", args, 1); err != nil {
+			return err
+		}
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, element := range arr.Elements {
+		s, ok := element.(*object.String)
+		if !ok {
+			return newError("element %d of the array passed to `join` must be a STRING, got %s", i, element.Type())
+		}
+		parts[i] = s.Value
+	}
+
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+// the trim builtins remove white space, or the characters of their second
+// argument, from one or both ends of a string
+func trim(name string, cut func(string, string) string, cutSpace func(string, func(rune) bool) string) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 2); err != nil {
+			return err
+		}
+		s, err := stringArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			return &object.String{Value: cutSpace(s, unicode.IsSpace)}
+		}
+
+		cutset, err := stringArgument(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: cut(s, cutset)}
+	}
+}
+
+// replace the first n occurrences of old in s, all of them without n
+func replace(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("replace", args, 3, 4); err != nil {
+		return err
+	}
+
+	strs := make([]string, 3)
+	for i := range strs {
+		s, err := stringArgument("replace", args, i)
+		if err != nil {
+			return err
+		}
+		strs[i] = s
+	}
+
+	n := int64(-1)
+	if len(args) == 4 {
+		var err *object.Error
+		if n, err = integerArgument("replace", args, 3); err != nil {
+			return err
+		}
+	}
+
+	s, old, new := strs[0], strs[1], strs[2]
+	if count := int64(strings.Count(s, old)); n < 0 || n > count {
+		n = count
+	}
+	if err := checkLength("replace", int64(len(s))+n*int64(len(new)-len(old))); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.Replace(s, old, new, int(n))}
+}
+
+// a builtin taking two strings and answering with a boolean
+func stringPredicate(name string, test func(s, sub string) bool) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 2, 2); err != nil {
+			return err
+		}
+		s, err := stringArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+		sub, err := stringArgument(name, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return nativeBoolToBooleanObject(test(s, sub))
+	}
+}
+
+var (
+	contains   = stringPredicate("contains", strings.Contains)
+	startsWith = stringPredicate("starts_with", strings.HasPrefix)
+	endsWith   = stringPredicate("ends_with", strings.HasSuffix)
+)
+
+// position in characters of the first sub in s, -1 when there is none
+func indexOf(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("index_of", args, 2, 2); err != nil {
+		return err
+	}
+	s, err := stringArgument("index_of", args, 0)
+	if err != nil {
+		return err
+	}
+	sub, err := stringArgument("index_of", args, 1)
+	if err != nil {
+		return err
+	}
+
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return &object.Integer{Value: -1}
+	}
+
+	return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+}
+
+// a builtin mapping one string to another
+func stringFunction(name string, fn func(string) string) object.BuiltinFunction {
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 1, 1); err != nil {
+			return err
+		}
+		s, err := stringArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		return &object.String{Value: fn(s)}
+	}
+}
+
+var (
+	upper = stringFunction("upper", strings.ToUpper)
+	lower = stringFunction("lower", strings.ToLower)
+)
+
+func repeat(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("repeat", args, 2, 2); err != nil {
+		return err
+	}
+	s, err := stringArgument("repeat", args, 0)
+	if err != nil {
+		return err
+	}
+	n, err := integerArgument("repeat", args, 1)
+	if err != nil {
+		return err
+	}
+
+	if n < 0 {
+		return newError("`repeat` cannot repeat a string %d times", n)
+	}
+	if n > 0 {
+		if err := checkLength("repeat", int64(len(s))*min(n, int64(maxLength)+1)); err != nil {
+			return err
+		}
+	}
+
+	return &object.String{Value: strings.Repeat(s, int(n))}
+}
+
+// the pad builtins fill a string up to a width in characters with spaces,
+// or the character given as third argument, at its start or its end
+func pad(start bool) object.BuiltinFunction {
+	name := "pad_end"
+	if start {
+		name = "pad_start"
+	}
+
+	return func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArity(name, args, 2, 3); err != nil {
+			return err
+		}
+		s, err := stringArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+		width, err := integerArgument(name, args, 1)
+		if err != nil {
+			return err
+		}
+		fill := " "
+		if len(args) == 3 {
+			if fill, err = stringArgument(name, args, 2); err != nil {
+				return err
+			}
+			if utf8.RuneCountInString(fill) != 1 {
+				return newError("`%s` pads with a single character, got %s", name, strconv.Quote(fill))
+			}
+		}
+
+		missing := width - int64(utf8.RuneCountInString(s))
+		if missing <= 0 {
+			return &object.String{Value: s}
+		}
+		if err := checkLength(name, width); err != nil {
+			return err
+		}
+
+		padding := strings.Repeat(fill, int(missing))
+		if start {
+			return &object.String{Value: padding + s}
+		}
+		return &object.String{Value: s + padding}
+	}
+}
+
+// the characters of a string, each as a string of its own
+func chars(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("chars", args, 1, 1); err != nil {
+		return err
+	}
+	s, err := stringArgument("chars", args, 0)
+	if err != nil {
+		return err
+	}
+
+	return stringArray(strings.Split(s, ""))
+}
+
+/**
+ * format replaces each {} in its first argument by the next value, {n} by
+ * the value at index n, strings are inserted as they are and other values as
+ * they are printed, {{ and }} stand for the braces themselves, every value
+ * has to be used
+ */
+func format(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("format", args, 1, -1); err != nil {
+		return err
+	}
+	template, err := stringArgument("format", args, 0)
+	if err != nil {
+		return err
+	}
+	values := args[1:]
+	used := make([]bool, len(values))
+
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '{' && strings.HasPrefix(template[i:], "{{"):
+			out.WriteByte('{')
+			i++
+
+		case c == '}' && strings.HasPrefix(template[i:], "}}"):
+			out.WriteByte('}')
+			i++
+
+		case c == '}':
+			return newError("unmatched } at %d in the template of `format`", i)
+
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return newError("unclosed { at %d in the template of `format`", i)
+			}
+
+			index := next
+			if spec := template[i+1 : i+end]; spec == "" {
+				next++
+			} else {
+				n, err := strconv.Atoi(spec)
+				if err != nil || n < 0 {
+					return newError("invalid placeholder {%s} in the template of `format`", spec)
+				}
+				index = n
+			}
+			if index >= len(values) {
+				return newError("placeholder %d of `format` has no value, got %d values", index, len(values))
+			}
+
+			used[index] = true
+			if s, ok := values[index].(*object.String); ok {
+				out.WriteString(s.Value)
+			} else {
+				out.WriteString(values[index].Inspect())
+			}
+			i += end
+
+		default:
+			out.WriteByte(c)
+		}
+
+		if err := checkLength("format", int64(out.Len())); err != nil {
+			return err
+		}
+	}
+
+	for i, u := range used {
+		if !u {
+			return newError("value %d passed to `format` is not used by the template", i)
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,308 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math"
+	"strconv"
+	"time"
+
+	// time zones are looked up in a copy of the tz database built into the
+	// interpreter, so they work the same on every system
+	_ "time/tzdata"
+)
+
+/**
+ * times and durations, a time plus or minus a duration is a time, the
+ * difference of two times a duration, layouts are written with the
+ * reference time of Go, Mon Jan 2 15:04:05 MST 2006, or named by one of
+ * the layouts below
+ */
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"now":         now,
+		"parse_time":  parseTime,
+		"format_time": formatTime,
+		"duration":    duration,
+		"in_zone":     inZone,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+	}
+}
+
+var layouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// the layout given as argument i of the builtin name, RFC 3339 without it
+func layoutArgument(name string, args []object.Object, i int) (string, *object.Error) {
+	if len(args) <= i {
+		return time.RFC3339, nil
+	}
+	layout, err := stringArgument(name, args, i)
+	if err != nil {
+		return "", err
+	}
+
+	if named, ok := layouts[layout]; ok {
+		return named, nil
+	}
+	return layout, nil
+}
+
+// the time zone called by the name given as argument i of the builtin name
+func zoneArgument(name string, args []object.Object, i int) (*time.Location, *object.Error) {
+	zone, err := stringArgument(name, args, i)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, loadErr := time.LoadLocation(zone)
+	if loadErr != nil || zone == "" {
+		return nil, newError("`%s` does not know the time zone %s", name, strconv.Quote(zone))
+	}
+	return loc, nil
+}
+
+func timeArgument(name string, args []object.Object, i int) (time.Time, *object.Error) {
+	t, ok := args[i].(*object.Time)
+	if !ok {
+		return time.Time{}, argumentError(name, i, object.TIME_OBJ, args[i])
+	}
+
+	return t.Value, nil
+}
+
+// the time by the clock of the host
+func now(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("now", args, 0, 0); err != nil {
+		return err
+	}
+
+	return &object.Time{Value: env.Now()}
+}
+
+// parse_time(s, layout?, zone?) reads a time written in layout, zone is the
+// time zone of times which do not give their offset, UTC by default
+func parseTime(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("parse_time", args, 1, 3); err != nil {
+		return err
+	}
+	s, err := stringArgument("parse_time", args, 0)
+	if err != nil {
+		return err
+	}
+	layout, err := layoutArgument("parse_time", args, 1)
+	if err != nil {
+		return err
+	}
+	loc := time.UTC
+	if len(args) == 3 {
+		if loc, err = zoneArgument("parse_time", args, 2); err != nil {
+			return err
+		}
+	}
+
+	t, parseErr := time.ParseInLocation(layout, s, loc)
+	if parseErr != nil {
+		return newError("`parse_time` cannot read %s with the layout %s", strconv.Quote(s), strconv.Quote(layout))
+	}
+	return &object.Time{Value: t}
+}
+
+// format_time(t, layout?) writes t in layout, RFC 3339 by default
+func formatTime(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("format_time", args, 1, 2); err != nil {
+		return err
+	}
+	t, err := timeArgument("format_time", args, 0)
+	if err != nil {
+		return err
+	}
+	layout, err := layoutArgument("format_time", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: t.Format(layout)}
+}
+
+// duration(s) reads a duration such as "1h30m" or "-1.5s"
+func duration(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("duration", args, 1, 1); err != nil {
+		return err
+	}
+	s, err := stringArgument("duration", args, 0)
+	if err != nil {
+		return err
+	}
+
+	d, parseErr := time.ParseDuration(s)
+	if parseErr != nil {
+		return newError("`duration` cannot read %s, write it like \"1h30m\" with the units h, m, s, ms, us and ns", strconv.Quote(s))
+	}
+	return &object.Duration{Value: d}
+}
+
+// in_zone(t, zone) is the same instant as t shown in the time zone zone,
+// such as "Europe/Paris", "UTC" or "Local"
+func inZone(env *object.Environment, args ...object.Object) object.Object {
+	if err := checkArity("in_zone", args, 2, 2); err != nil {
+		return err
+	}
+	t, err := timeArgument("in_zone", args, 0)
+	if err != nil {
+		return err
+	}
+	loc, err := zoneArgument("in_zone", args, 1)
+	if err != nil {
+		return err
+	}
+
+	return &object.Time{Value: t.In(loc)}
+}
+
+func isTemporal(obj object.Object) bool {
+	return obj.Type() == object.TIME_OBJ || obj.Type() == object.DURATION_OBJ
+}
+
+/**
+ * the operators on times and durations, durations add up and scale by
+ * numbers, dividing one duration by another gives a float, times compare by
+ * the instant they stand for whatever their time zones
+ */
+func evalTemporalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Time:
+		switch right := right.(type) {
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: left.Value.Add(right.Value)}
+			case "-":
+				if right.Value == math.MinInt64 {
+					return newError("duration out of range: -%s", right.Inspect())
+				}
+				return &object.Time{Value: left.Value.Add(-right.Value)}
+			}
+
+		case *object.Time:
+			switch operator {
+			case "-":
+				return &object.Duration{Value: left.Value.Sub(right.Value)}
+			case "<":
+				return nativeBoolToBooleanObject(left.Value.Before(right.Value))
+			case ">":
+				return nativeBoolToBooleanObject(left.Value.After(right.Value))
+			case "==":
+				return nativeBoolToBooleanObject(left.Value.Equal(right.Value))
+			case "!=":
+				return nativeBoolToBooleanObject(!left.Value.Equal(right.Value))
+			}
+		}
+
+	case *object.Duration:
+		switch right := right.(type) {
+		case *object.Duration:
+			return evalDurationInfixExpression(operator, left, right)
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: right.Value.Add(left.Value)}
+			}
+		case *object.Integer, *object.Float:
+			if operator == "*" || operator == "/" {
+				return scaleDuration(operator, left, right)
+			}
+		}
+
+	case *object.Integer, *object.Float:
+		if right, ok := right.(*object.Duration); ok && operator == "*" {
+			return scaleDuration(operator, right, left)
+		}
+	}
+
+	switch {
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalDurationInfixExpression(operator string, left, right *object.Duration) object.Object {
+	a, b := left.Value, right.Value
+
+	switch operator {
+	case "+", "-":
+		if operator == "-" {
+			b = -b
+		}
+		sum := a + b
+		if (b > 0 && sum < a) || (b < 0 && sum > a) || (operator == "-" && right.Value == math.MinInt64) {
+			return newError("duration out of range: %s %s %s", left.Inspect(), operator, right.Inspect())
+		}
+		return &object.Duration{Value: sum}
+	case "/":
+		if b == 0 {
+			return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: float64(a) / float64(b)}
+	case "<":
+		return nativeBoolToBooleanObject(a < b)
+	case ">":
+		return nativeBoolToBooleanObject(a > b)
+	case "==":
+		return nativeBoolToBooleanObject(a == b)
+	case "!=":
+		return nativeBoolToBooleanObject(a != b)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// a duration multiplied or divided by a number
+func scaleDuration(operator string, d *object.Duration, by object.Object) object.Object {
+	if n, ok := by.(*object.Integer); ok {
+		if operator == "*" {
+			if product, ok := multiply(int64(d.Value), n.Value); ok {
+				return &object.Duration{Value: time.Duration(product)}
+			}
+			return newError("duration out of range: %s * %d", d.Inspect(), n.Value)
+		}
+		if n.Value == 0 {
+			return newError("division by zero: %s / 0", d.Inspect())
+		}
+		if n.Value == -1 && d.Value == math.MinInt64 {
+			return newError("duration out of range: %s / -1", d.Inspect())
+		}
+		return &object.Duration{Value: d.Value / time.Duration(n.Value)}
+	}
+
+	f := toFloat(by)
+	if operator == "/" && f == 0 {
+		return newError("division by zero: %s / %s", d.Inspect(), by.Inspect())
+	}
+	scaled := float64(d.Value) * f
+	if operator == "/" {
+		scaled = float64(d.Value) / f
+	}
+	if math.IsNaN(scaled) || scaled < math.MinInt64 || scaled >= math.MaxInt64 {
+		return newError("duration out of range: %s %s %s", d.Inspect(), operator, by.Inspect())
+	}
+	return &object.Duration{Value: time.Duration(math.Round(scaled))}
+}
@@ -0,0 +1,199 @@
+package format
+
+import (
+	"interpreter/ast"
+	"strings"
+)
+
+// binding strength of infix operators, mirrors the precedences of the parser
+var precedences = map[string]int{
+	"==": 1,
+	"!=": 1,
+	"<":  2,
+	">":  2,
+	"+":  3,
+	"-":  3,
+	"*":  4,
+	"/":  4,
+}
+
+// operands of prefix operators and anything indexed or called bind tighter
+// than every infix operator
+const (
+	prefixPrecedence = 5
+	callPrecedence   = 6
+)
+
+type printer struct {
+	out    strings.Builder
+	indent string
+	depth  int
+}
+
+/**
+ * Program renders program as canonical source, one statement per line and
+ * blocks indented by indent, only the parentheses needed to keep the meaning
+ * are printed
+ */
+func Program(program *ast.Program, indent string) string {
+	p := &printer{indent: indent}
+	for _, stmt := range program.Statements {
+		p.statement(stmt)
+	}
+
+	return p.out.String()
+}
+
+func (p *printer) line(s string) {
+	p.out.WriteString(strings.Repeat(p.indent, p.depth))
+	p.out.WriteString(s)
+	p.out.WriteString("\n")
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		let := "let "
+		if stmt.Exported {
+			let = "export let "
+		}
+		p.line(let + stmt.Name.Value + " = " + p.expression(stmt.Value, 0) + ";")
+
+	case *ast.ImportStatement:
+		p.line("import " + p.expression(stmt.Path, 0) + " as " + stmt.Name.Value + ";")
+
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			p.line("return;")
+			return
+		}
+		p.line("return " + p.expression(stmt.ReturnValue, 0) + ";")
+
+	case *ast.ExpressionStatement:
+		src := p.expression(stmt.Expression, 0)
+		// an if expression reads as a statement, it needs no terminator
+		if _, ok := stmt.Expression.(*ast.IfExpression); ok {
+			p.line(src)
+			return
+		}
+		p.line(src + ";")
+	}
+}
+
+// the statements of block between braces, the closing brace is left at the
+// indentation of the line the block is opened on
+func (p *printer) block(block *ast.BlockStatement) string {
+	if block == nil || len(block.Statements) == 0 {
+		return "{}"
+	}
+
+	inner := &printer{indent: p.indent, depth: p.depth + 1}
+	for _, stmt := range block.Statements {
+		inner.statement(stmt)
+	}
+
+	return "{\n" + inner.out.String() + strings.Repeat(p.indent, p.depth) + "}"
+}
+
+// render e, wrapping it in parentheses when it binds looser than the
+// surrounding operator
+func (p *printer) expression(e ast.Expression, outer int) string {
+	switch e := e.(type) {
+	case nil:
+		return ""
+
+	case *ast.Identifier:
+		return e.Value
+
+	case *ast.IntegerLiteral:
+		return e.String()
+
+	case *ast.FloatLiteral:
+		return e.String()
+
+	case *ast.Boolean:
+		return e.String()
+
+	case *ast.StringLiteral:
+		return `"` + e.Value + `"`
+
+	case *ast.PrefixExpression:
+		return wrap(e.Operator+p.expression(e.Right, prefixPrecedence), prefixPrecedence, outer)
+
+	case *ast.InfixExpression:
+		prec := precedences[e.Operator]
+		// operators are left associative so a right operand of the same
+		// precedence has to keep its parentheses
+		src := p.expression(e.Left, prec) + " " + e.Operator + " " + p.expression(e.Right, prec+1)
+		return wrap(src, prec, outer)
+
+	case *ast.CallExpression:
+		args := []string{}
+		for _, a := range e.Arguments {
+			args = append(args, p.expression(a, 0))
+		}
+		return p.expression(e.Function, callPrecedence) + "(" + strings.Join(args, ", ") + ")"
+
+	case *ast.IndexExpression:
+		return p.expression(e.Left, callPrecedence) + "[" + p.expression(e.Index, 0) + "]"
+
+	case *ast.SpreadExpression:
+		return "..." + p.expression(e.Value, 0)
+
+	case *ast.ImportExpression:
+		return "import(" + p.expression(e.Path, 0) + ")"
+
+	case *ast.NamedArgument:
+		return e.Name.Value + ": " + p.expression(e.Value, 0)
+
+	case *ast.ArrayLiteral:
+		elements := []string{}
+		for _, el := range e.Elements {
+			elements = append(elements, p.expression(el, 0))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *ast.HashLiteral:
+		pairs := []string{}
+		for _, key := range e.Keys {
+			if _, ok := key.(*ast.SpreadExpression); ok {
+				pairs = append(pairs, p.expression(key, 0))
+				continue
+			}
+			pairs = append(pairs, p.expression(key, 0)+": "+p.expression(e.Pairs[key], 0))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+
+	case *ast.FunctionLiteral:
+		params := []string{}
+		for i, param := range e.Parameters {
+			switch {
+			case e.Variadic && i == len(e.Parameters)-1:
+				params = append(params, "..."+param.Value)
+			case e.Defaults[param] != nil:
+				params = append(params, param.Value+" = "+p.expression(e.Defaults[param], 0))
+			default:
+				params = append(params, param.Value)
+			}
+		}
+		return "fn(" + strings.Join(params, ", ") + ") " + p.block(e.Body)
+
+	case *ast.IfExpression:
+		src := "if (" + p.expression(e.Condition, 0) + ") " + p.block(e.Consequence)
+		if e.Alternative != nil {
+			src += " else " + p.block(e.Alternative)
+		}
+		return src
+
+	default:
+		return e.String()
+	}
+}
+
+func wrap(src string, prec, outer int) string {
+	if prec < outer {
+		return "(" + src + ")"
+	}
+
+	return src
+}
@@ -0,0 +1,64 @@
+package format
+
+import (
+	"interpreter/lexer"
+	"interpreter/parser"
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let add = fn(a,b){a+b}; add(1,2)",
+			"let add = fn(a, b) {\n    a + b;\n};\nadd(1, 2);\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); 1 - (2 - 3); (1 - 2) - 3",
+			"(1 + 2) * 3;\n1 + 2 * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a + b); !-a; (-a)[0]; (a + b)(c)",
+			"-(a + b);\n!-a;\n(-a)[0];\n(a + b)(c);\n"},
+		{`if (x > 1) { return "big" } else { let y = x; y }`,
+			"if (x > 1) {\n    return \"big\";\n} else {\n    let y = x;\n    y;\n}\n"},
+		{`{"b": [1,2], "a": {}}["b"][0]`,
+			"{\"b\": [1, 2], \"a\": {}}[\"b\"][0];\n"},
+		{"let log = fn(level,prefix=\"[\"+level+\"]\",...parts){parts}",
+			"let log = fn(level, prefix = \"[\" + level + \"]\", ...parts) {\n    parts;\n};\n"},
+		{"connect(...[1+1],host:\"x\",port:80); [...a,...b]; {...defaults,\"k\":1}",
+			"connect(...[1 + 1], host: \"x\", port: 80);\n[...a, ...b];\n{...defaults, \"k\": 1};\n"},
+		{"import \"lib.monkey\" as lib\nexport let x=import(\"a\"+\".monkey\")[\"y\"]",
+			"import \"lib.monkey\" as lib;\nexport let x = import(\"a\" + \".monkey\")[\"y\"];\n"},
+		{"let f = fn() {}; fn(x) { fn(y) { x + y } }(1)(2)",
+			"let f = fn() {};\nfn(x) {\n    fn(y) {\n        x + y;\n    };\n}(1)(2);\n"},
+	}
+
+	for _, test := range tests {
+		p := parser.New(lexer.New(test.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parsing %q failed: %v", test.input, p.Errors())
+		}
+
+		formatted := Program(program, "    ")
+		if formatted != test.expected {
+			t.Errorf("formatting %q got %q, but want %q", test.input, formatted, test.expected)
+			continue
+		}
+
+		// formatted source has to mean the same and format to itself again
+		again := parser.New(lexer.New(formatted))
+		reparsed := again.ParseProgram()
+		if len(again.Errors()) != 0 {
+			t.Errorf("formatted source %q does not parse: %v", formatted, again.Errors())
+			continue
+		}
+
+		if reparsed.String() != program.String() {
+			t.Errorf("formatting changed the program, got %q, but want %q", reparsed.String(), program.String())
+		}
+
+		if Program(reparsed, "    ") != formatted {
+			t.Errorf("formatting %q is not stable", formatted)
+		}
+	}
+}
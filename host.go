@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"interpreter/object"
+	"io"
+)
+
+// hostFlags decide where scripts find their imports and which files they may
+// use, every command running scripts takes them
+type hostFlags struct {
+	search *string
+	read   *string
+	write  *string
+}
+
+func addHostFlags(flags *flag.FlagSet) hostFlags {
+	return hostFlags{
+		search: flags.String("path", "", "directories searched for imports, defaults to $MONKEYPATH"),
+		read:   flags.String("allow-read", "", "directories scripts may read files from"),
+		write:  flags.String("allow-write", "", "directories scripts may read and write files in"),
+	}
+}
+
+func (h hostFlags) paths() []string {
+	return object.SearchPaths(*h.search)
+}
+
+func (h hostFlags) files() *object.Files {
+	return object.NewFiles(object.AllowedRoots(*h.read, *h.write)...)
+}
+
+// a host writing to out with the imports and files the flags allow
+func (h hostFlags) host(out io.Writer) *object.Host {
+	return &object.Host{Out: out, Modules: object.NewModules(h.paths()...), Files: h.files()}
+}
@@ -0,0 +1,60 @@
+package lexer
+
+import (
+	"interpreter/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// seeds are the conformance programs and inputs which used to break the lexer
+func addSeeds(f *testing.F) {
+	programs, _ := filepath.Glob(filepath.Join("..", "conformance", "testdata", "*.monkey"))
+	for _, program := range programs {
+		if src, err := os.ReadFile(program); err == nil {
+			f.Add(string(src))
+		}
+	}
+
+	for _, input := range []string{
+		`=+(){},;`,
+		`let five = 5; let add = fn(x, y) { x + y; }; add(five, 10);`,
+		`!-/*5; 5 < 10 > 5; 10 == 10; 10 != 9;`,
+		`"foo bar" "" [1, 2]; {"foo": "bar"}`,
+		`"unterminated`,
+		`"`,
+		"a\x00b",
+		"\xff\xfe",
+	} {
+		f.Add(input)
+	}
+}
+
+/**
+ * FuzzNextToken lexes arbitrary input, the lexer must not panic, has to
+ * reach EOF within one token per byte and keep reporting EOF afterwards
+ */
+func FuzzNextToken(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+
+		count := 0
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			count++
+			if count > len(input) {
+				t.Fatalf("more tokens than bytes in %q", input)
+			}
+			if tok.Line < 1 || tok.Column < 1 {
+				t.Fatalf("token %q at %d:%d", tok.Literal, tok.Line, tok.Column)
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			if tok := l.NextToken(); tok.Type != token.EOF {
+				t.Fatalf("got %s after EOF in %q", tok.Type, input)
+			}
+		}
+	})
+}
@@ -7,10 +7,12 @@ type Lexer struct {
 	position     int  // position in input
 	readPosition int  // current position in input after current char
 	ch           byte // current char going through lexering
+	line         int  // line of the current char
+	column       int  // byte column of the current char within its line
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	token.CheckUpIdentifier("let")
 	return l
@@ -22,6 +24,9 @@ func New(input string) *Lexer {
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhiteSpace()
+	// remember where the token starts before reading past it
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -64,8 +69,13 @@ func (l *Lexer) NextToken() token.Token {
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		// a NUL byte inside the input is not the end of it
+		if l.position < len(l.input) {
+			tok = newToken(token.ILLEGAL, l.ch)
+		} else {
+			tok.Literal = ""
+			tok.Type = token.EOF
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -75,14 +85,23 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.CheckUpIdentifier(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -90,6 +109,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -99,6 +119,12 @@ func (l *Lexer) NextToken() token.Token {
  * readPostion record the current reading char
  */
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -129,13 +155,22 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// an integer, or a float when the digits are followed by a dot and more digits
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return l.input[position:l.position], token.INT
+	}
 
-	return l.input[position:l.position]
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+
+	return l.input[position:l.position], token.FLOAT
 }
 
 func isDigit(ch byte) bool {
@@ -156,12 +191,22 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// the char n places after the current one, peekCharAt(1) is peekChar
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.position+n >= len(l.input) {
+		return 0
+	}
+
+	return l.input[l.position+n]
+}
+
+// an unterminated string runs to the end of the input
 func (l *Lexer) readString() string {
 	position := l.position + 1
 	for {
 		l.readChar()
 
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '"' || l.position >= len(l.input) {
 			break
 		}
 	}
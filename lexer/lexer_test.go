@@ -169,4 +169,108 @@ if (5 < 10) {
 			}
 		}
 	})
+	t.Run("testing output of ellipsis", func(t *testing.T) {
+
+		input := `fn(a, ...rest) .. .`
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.IDENT, "a"},
+			{token.COMMA, ","},
+			{token.ELLIPSIS, "..."},
+			{token.IDENT, "rest"},
+			{token.RPAREN, ")"},
+			{token.ILLEGAL, "."},
+			{token.ILLEGAL, "."},
+			{token.ILLEGAL, "."},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+		for i, test := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != test.expectedType {
+				t.Errorf("tests[%d], expected token type %q but got %q", i, test.expectedType, tok.Type)
+			}
+
+			if tok.Literal != test.expectedLiteral {
+				t.Errorf("tests[%d], expected token literal %s but got %s", i, test.expectedLiteral, tok.Literal)
+			}
+		}
+	})
+	t.Run("testing output of floats", func(t *testing.T) {
+
+		input := `3.14 10.0 5. [1...x] 0.5`
+
+		tests := []struct {
+			expectedType    token.TokenType
+			expectedLiteral string
+		}{
+			{token.FLOAT, "3.14"},
+			{token.FLOAT, "10.0"},
+			{token.INT, "5"},
+			{token.ILLEGAL, "."},
+			{token.LBRACKET, "["},
+			{token.INT, "1"},
+			{token.ELLIPSIS, "..."},
+			{token.IDENT, "x"},
+			{token.RBRACKET, "]"},
+			{token.FLOAT, "0.5"},
+			{token.EOF, ""},
+		}
+
+		l := New(input)
+		for i, test := range tests {
+			tok := l.NextToken()
+
+			if tok.Type != test.expectedType {
+				t.Errorf("tests[%d], expected token type %q but got %q", i, test.expectedType, tok.Type)
+			}
+
+			if tok.Literal != test.expectedLiteral {
+				t.Errorf("tests[%d], expected token literal %s but got %s", i, test.expectedLiteral, tok.Literal)
+			}
+		}
+	})
+	t.Run("testing line and column of tokens", func(t *testing.T) {
+
+		input := "let x = 5;\n  add(x,\n\t\"hi\")"
+
+		tests := []struct {
+			expectedLiteral string
+			expectedLine    int
+			expectedColumn  int
+		}{
+			{"let", 1, 1},
+			{"x", 1, 5},
+			{"=", 1, 7},
+			{"5", 1, 9},
+			{";", 1, 10},
+			{"add", 2, 3},
+			{"(", 2, 6},
+			{"x", 2, 7},
+			{",", 2, 8},
+			{"hi", 3, 2},
+			{")", 3, 6},
+			{"", 3, 7},
+		}
+
+		l := New(input)
+		for i, test := range tests {
+			tok := l.NextToken()
+
+			if tok.Literal != test.expectedLiteral {
+				t.Errorf("tests[%d], expected token literal %s but got %s", i, test.expectedLiteral, tok.Literal)
+			}
+
+			if tok.Line != test.expectedLine || tok.Column != test.expectedColumn {
+				t.Errorf("tests[%d], expected %q at %d:%d but got %d:%d", i, tok.Literal, test.expectedLine, test.expectedColumn, tok.Line, tok.Column)
+			}
+		}
+	})
 }
@@ -0,0 +1,307 @@
+package lsp
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/token"
+	"strings"
+)
+
+// binding is a name introduced by a let statement, an import or a function parameter
+type binding struct {
+	name   *ast.Identifier
+	value  ast.Expression       // the bound expression of a let, nil for parameters
+	fn     *ast.FunctionLiteral // the function a parameter belongs to
+	module *ast.ImportStatement // the import binding the name
+}
+
+func (b *binding) isParameter() bool { return b.fn != nil }
+
+/**
+ * scope holds the bindings of the program or of one function body, blocks of
+ * if expressions share the scope around them just like the evaluator does
+ */
+type scope struct {
+	parent *scope
+	names  map[string][]*binding
+	start  token.Token // start and end of the function, unset for the program
+	end    token.Token
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, names: make(map[string][]*binding)}
+}
+
+func (s *scope) declare(b *binding) {
+	s.names[b.name.Value] = append(s.names[b.name.Value], b)
+}
+
+// find the binding a use of name at tok refers to, the latest one declared
+// before the use wins and a binding declared later is the fallback, as
+// function bodies run after the whole scope has been evaluated
+func (s *scope) lookup(name string, tok token.Token) *binding {
+	for sc := s; sc != nil; sc = sc.parent {
+		candidates := sc.names[name]
+		if len(candidates) == 0 {
+			continue
+		}
+
+		found := candidates[0]
+		for _, b := range candidates {
+			if before(b.name.Token, tok) {
+				found = b
+			}
+		}
+		return found
+	}
+
+	return nil
+}
+
+// report whether pos, a line and byte column, falls inside the function of s
+func (s *scope) contains(line, column int) bool {
+	if s.parent == nil {
+		return true
+	}
+
+	afterStart := line > s.start.Line || line == s.start.Line && column >= s.start.Column
+	beforeEnd := line < s.end.Line || line == s.end.Line && column <= s.end.Column
+	return afterStart && beforeEnd
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// document is an open file along with everything derived from its text
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	program *ast.Program
+	errors  []parser.Error
+
+	global *scope
+	scopes []*scope
+	// every identifier in source order and what each of them refers to
+	idents []*ast.Identifier
+	refs   map[*ast.Identifier]*binding
+}
+
+func newDocument(uri, text string) *document {
+	p := parser.New(lexer.New(text))
+	d := &document{
+		uri:     uri,
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+		program: p.ParseProgram(),
+		errors:  p.ErrorDetails(),
+		refs:    make(map[*ast.Identifier]*binding),
+	}
+
+	d.global = newScope(nil)
+	d.scopes = append(d.scopes, d.global)
+	d.declare(d.program, d.global)
+	d.resolve(d.program, d.global)
+
+	return d
+}
+
+// declare every let of the scope owning node, without entering nested functions
+func (d *document) declare(node ast.Node, sc *scope) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			b := &binding{name: n.Name, value: n.Value}
+			sc.declare(b)
+			d.refs[n.Name] = b
+		case *ast.ImportStatement:
+			b := &binding{name: n.Name, module: n}
+			sc.declare(b)
+			d.refs[n.Name] = b
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+}
+
+// link every identifier below node to its binding, opening a scope per function
+func (d *document) resolve(node ast.Node, sc *scope) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			inner := newScope(sc)
+			inner.start = n.Token
+			if n.Body != nil {
+				inner.end = n.Body.EndToken
+			}
+			d.scopes = append(d.scopes, inner)
+
+			for _, param := range n.Parameters {
+				// a default can refer to the parameters before it
+				if def := n.Defaults[param]; def != nil {
+					d.resolve(def, inner)
+				}
+
+				b := &binding{name: param, fn: n}
+				inner.declare(b)
+				d.refs[param] = b
+				d.idents = append(d.idents, param)
+			}
+
+			if n.Body != nil {
+				d.declare(n.Body, inner)
+				d.resolve(n.Body, inner)
+			}
+			return false
+
+		case *ast.Identifier:
+			d.idents = append(d.idents, n)
+			if _, isDefinition := d.refs[n]; !isDefinition {
+				if b := sc.lookup(n.Value, n.Token); b != nil {
+					d.refs[n] = b
+				}
+			}
+		}
+		return true
+	})
+}
+
+// the identifier under pos, if any
+func (d *document) identAt(pos Position) *ast.Identifier {
+	line, column := d.byteColumn(pos)
+	for _, ident := range d.idents {
+		if ident.Token.Line == line && column >= ident.Token.Column && column <= ident.Token.Column+len(ident.Value) {
+			return ident
+		}
+	}
+
+	return nil
+}
+
+// innermost scope around pos
+func (d *document) scopeAt(pos Position) *scope {
+	line, column := d.byteColumn(pos)
+	found := d.global
+	for _, sc := range d.scopes {
+		// scopes are appended outermost first, so the last match is the innermost
+		if sc.contains(line, column) {
+			found = sc
+		}
+	}
+
+	return found
+}
+
+// convert between protocol positions, 0 based lines and UTF-16 characters,
+// and token positions, 1 based lines and byte columns
+func (d *document) byteColumn(pos Position) (int, int) {
+	line := pos.Line + 1
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return line, pos.Character + 1
+	}
+
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return line, i + 1
+		}
+		units += utf16Len(r)
+	}
+
+	return line, len(text) + 1
+}
+
+func (d *document) position(line, column int) Position {
+	if line < 1 || line > len(d.lines) {
+		return Position{Line: max(line-1, 0), Character: max(column-1, 0)}
+	}
+
+	text := d.lines[line-1]
+	if column-1 > len(text) {
+		column = len(text) + 1
+	}
+
+	units := 0
+	for _, r := range text[:column-1] {
+		units += utf16Len(r)
+	}
+
+	return Position{Line: line - 1, Character: units}
+}
+
+// runes outside the basic multilingual plane take two UTF-16 units
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// range covering the whole of tok
+func (d *document) tokenRange(tok token.Token) Range {
+	length := len(tok.Literal)
+	if tok.Type == token.STRING {
+		length += 2
+	}
+	if tok.Type == token.EOF {
+		length = 0
+	}
+
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+length),
+	}
+}
+
+// range from the first to the last token of node
+func (d *document) nodeRange(node ast.Node, start token.Token) Range {
+	end := start
+	endLength := len(start.Literal)
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		var tok token.Token
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			tok = n.EndToken
+		case *ast.Identifier:
+			tok = n.Token
+		case *ast.IntegerLiteral:
+			tok = n.Token
+		case *ast.FloatLiteral:
+			tok = n.Token
+		case *ast.StringLiteral:
+			tok = n.Token
+		case *ast.Boolean:
+			tok = n.Token
+		default:
+			return true
+		}
+
+		if tok.Type != token.EOF && before(end, tok) {
+			end = tok
+			endLength = len(tok.Literal)
+			if tok.Type == token.STRING {
+				endLength += 2
+			}
+		}
+		return true
+	})
+
+	return Range{
+		Start: d.position(start.Line, start.Column),
+		End:   d.position(end.Line, end.Column+endLength),
+	}
+}
+
+// the whole text, used to replace the document when formatting
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{
+		Start: Position{},
+		End:   d.position(last+1, len(d.lines[last])+1),
+	}
+}
@@ -0,0 +1,387 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/format"
+	"interpreter/rpc"
+	"interpreter/token"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Server answers language server requests for the Monkey documents an editor opens
+type Server struct {
+	out  io.Writer
+	mu   sync.Mutex // guards writes to out
+	docs map[string]*document
+
+	shutdown bool
+}
+
+func NewServer(out io.Writer) *Server {
+	return &Server{out: out, docs: make(map[string]*document)}
+}
+
+/**
+ * Serve reads requests from in and answers them on out until the client
+ * sends exit or closes the stream
+ */
+func Serve(in io.Reader, out io.Writer) error {
+	s := NewServer(out)
+	r := bufio.NewReader(in)
+
+	for {
+		body, err := rpc.ReadMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !s.handle(body) {
+			return nil
+		}
+	}
+}
+
+// handle one message, returns false once the client asked the server to exit
+func (s *Server) handle(body []byte) bool {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+		return true
+	}
+
+	if req.Method == "exit" {
+		return false
+	}
+
+	result, rerr := s.dispatch(req)
+	// notifications carry no id and never get an answer
+	if req.ID != nil {
+		s.reply(req.ID, result, rerr)
+	}
+
+	return true
+}
+
+func (s *Server) dispatch(req request) (any, *responseError) {
+	if s.shutdown && req.Method != "exit" {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		return decode(req.Params, &params, func() any {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+			return nil
+		})
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		return decode(req.Params, &params, func() any {
+			if n := len(params.ContentChanges); n > 0 {
+				s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+			}
+			return nil
+		})
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		return decode(req.Params, &params, func() any {
+			delete(s.docs, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+			return nil
+		})
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		return decode(req.Params, &params, func() any { return s.hover(params) })
+
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		return decode(req.Params, &params, func() any { return s.definition(params) })
+
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		return decode(req.Params, &params, func() any { return s.symbols(params) })
+
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		return decode(req.Params, &params, func() any { return s.completion(params) })
+
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		return decode(req.Params, &params, func() any { return s.formatting(params) })
+
+	default:
+		if req.ID == nil {
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q is not supported", req.Method)}
+	}
+}
+
+// unmarshal raw into params and run handler on success
+func decode(raw json.RawMessage, params any, handler func() any) (any, *responseError) {
+	if err := json.Unmarshal(raw, params); err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return handler(), nil
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rerr *responseError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result, Error: rerr})
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(msg any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rpc.WriteMessage(s.out, msg)
+}
+
+func (s *Server) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync":           1,
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+			"completionProvider":         map[string]any{},
+		},
+		"serverInfo": map[string]any{"name": "monkey-lsp"},
+	}
+}
+
+// reparse a document and send the editor its parse errors
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, e := range doc.errors {
+		start := doc.position(e.Line, e.Column)
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  e.Message,
+		})
+	}
+
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) any {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	ident := doc.identAt(params.Position)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if b, ok := doc.refs[ident]; ok {
+		text = "```monkey\n" + describe(b) + "\n```"
+	} else if docs, ok := evaluator.BuiltinDoc(ident.Value); ok {
+		signature, description, _ := strings.Cut(docs, "\n\n")
+		text = "```monkey\n" + signature + "\n```\n" + description
+	} else {
+		return nil
+	}
+
+	r := doc.tokenRange(ident.Token)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// one line summary of a binding as shown on hover and in symbols
+func describe(b *binding) string {
+	if b.isParameter() {
+		return "(parameter) " + b.name.Value + " of " + signature(b.fn)
+	}
+	if b.module != nil {
+		return b.module.String()
+	}
+
+	if fn, ok := b.value.(*ast.FunctionLiteral); ok {
+		return "let " + b.name.Value + " = " + signature(fn)
+	}
+
+	value := ""
+	if b.value != nil {
+		value = b.value.String()
+	}
+	if len(value) > 60 {
+		value = value[:57] + "..."
+	}
+
+	return "let " + b.name.Value + " = " + value
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	return "fn(" + ast.ParameterList(fn.Parameters, fn.Defaults, fn.Variadic) + ")"
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) any {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	ident := doc.identAt(params.Position)
+	if ident == nil {
+		return nil
+	}
+
+	b, ok := doc.refs[ident]
+	if !ok {
+		return nil
+	}
+
+	return Location{URI: doc.uri, Range: doc.tokenRange(b.name.Token)}
+}
+
+func (s *Server) symbols(params DocumentSymbolParams) any {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}
+	}
+
+	return doc.symbolsOf(doc.program)
+}
+
+// the lets of the scope owning node, each function lists the lets of its body
+func (d *document) symbolsOf(node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			symbol := DocumentSymbol{
+				Name:           n.Name.Value,
+				Kind:           symbolVariable,
+				Range:          d.nodeRange(n, n.Token),
+				SelectionRange: d.tokenRange(n.Name.Token),
+			}
+
+			if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+				symbol.Kind = symbolFunction
+				symbol.Detail = signature(fn)
+				if fn.Body != nil {
+					symbol.Children = d.symbolsOf(fn.Body)
+				}
+			}
+
+			symbols = append(symbols, symbol)
+			return false
+
+		case *ast.ImportStatement:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           n.Name.Value,
+				Kind:           symbolModule,
+				Detail:         n.Path.String(),
+				Range:          d.nodeRange(n, n.Token),
+				SelectionRange: d.tokenRange(n.Name.Token),
+			})
+
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+
+	return symbols
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) any {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if doc, ok := s.docs[params.TextDocument.URI]; ok {
+		for sc := doc.scopeAt(params.Position); sc != nil; sc = sc.parent {
+			names := []string{}
+			for name := range sc.names {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				bindings := sc.names[name]
+				b := bindings[len(bindings)-1]
+				kind := completionVariable
+				if _, ok := b.value.(*ast.FunctionLiteral); ok {
+					kind = completionFunction
+				}
+				add(CompletionItem{Label: name, Kind: kind, Detail: describe(b)})
+			}
+		}
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		docs, _ := evaluator.BuiltinDoc(name)
+		signature, _, _ := strings.Cut(docs, "\n\n")
+		add(CompletionItem{Label: name, Kind: completionFunction, Detail: signature})
+	}
+
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+
+	return items
+}
+
+// documents with parse errors are left alone, formatting them would lose code
+func (s *Server) formatting(params DocumentFormattingParams) any {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok || len(doc.errors) > 0 {
+		return nil
+	}
+
+	indent := "\t"
+	if params.Options.InsertSpaces {
+		indent = strings.Repeat(" ", max(params.Options.TabSize, 1))
+	}
+
+	formatted := format.Program(doc.program, indent)
+	if formatted == doc.text {
+		return []TextEdit{}
+	}
+
+	return []TextEdit{{Range: doc.fullRange(), NewText: formatted}}
+}
@@ -0,0 +1,367 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"interpreter/rpc"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.monkey"
+
+// client drives a server running in the same process over pipes
+type client struct {
+	t        *testing.T
+	toServer *io.PipeWriter
+	messages chan map[string]json.RawMessage
+	done     chan error
+	nextID   int
+	// latest diagnostics published for each document
+	diagnostics map[string][]Diagnostic
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+
+	c := &client{
+		t:           t,
+		toServer:    toServer,
+		messages:    make(chan map[string]json.RawMessage, 16),
+		done:        make(chan error, 1),
+		diagnostics: make(map[string][]Diagnostic),
+	}
+
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	// keep reading so the server never blocks on writing
+	go func() {
+		r := bufio.NewReader(fromServer)
+		for {
+			body, err := rpc.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("server sent invalid JSON %q", body)
+				continue
+			}
+			c.messages <- msg
+		}
+	}()
+
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, nil)
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	if err := rpc.WriteMessage(c.toServer, msg); err != nil {
+		c.t.Fatalf("sending %v failed: %v", msg["method"], err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+// send a request and wait for its answer, collecting notifications meanwhile
+func (c *client) call(method string, params any, result any) *responseError {
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]any{"id": id, "method": method, "params": params})
+
+	for msg := range c.messages {
+		if rawID, ok := msg["id"]; !ok {
+			c.handleNotification(msg)
+			continue
+		} else if string(rawID) != strconv.Itoa(id) {
+			c.t.Fatalf("answer for unexpected id %s", rawID)
+		}
+
+		if rawErr, ok := msg["error"]; ok {
+			var rerr responseError
+			json.Unmarshal(rawErr, &rerr)
+			return &rerr
+		}
+
+		if result != nil {
+			if err := json.Unmarshal(msg["result"], result); err != nil {
+				c.t.Fatalf("decoding result of %s failed: %v", method, err)
+			}
+		}
+		return nil
+	}
+
+	c.t.Fatalf("server hung up while waiting for %s", method)
+	return nil
+}
+
+func (c *client) handleNotification(msg map[string]json.RawMessage) {
+	var method string
+	json.Unmarshal(msg["method"], &method)
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+
+	var params PublishDiagnosticsParams
+	json.Unmarshal(msg["params"], &params)
+	c.diagnostics[params.URI] = params.Diagnostics
+}
+
+func (c *client) open(text string) {
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": testURI, "languageId": "monkey", "version": 1, "text": text},
+	})
+}
+
+func (c *client) close() {
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("server stopped with %v", err)
+	}
+	c.toServer.Close()
+}
+
+func position(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let total = add(1, 2);
+len([total])
+`
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	c.open("let x = 5;\nlet = 3;\n")
+	// a request after the notification makes sure the diagnostics arrived
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}, nil)
+
+	diagnostics := c.diagnostics[testURI]
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics for the broken let")
+	}
+
+	first := diagnostics[0]
+	if first.Range.Start.Line != 1 || first.Range.Start.Character != 4 {
+		t.Errorf("diagnostic at wrong position, got %+v", first.Range.Start)
+	}
+	if !strings.Contains(first.Message, "expected next token to be IDENT") {
+		t.Errorf("unexpected diagnostic message %q", first.Message)
+	}
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": testURI, "version": 2},
+		"contentChanges": []map[string]any{{"text": "let x = 5;\n"}},
+	})
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}, nil)
+	if n := len(c.diagnostics[testURI]); n != 0 {
+		t.Errorf("fixed document should have no diagnostics, got %d", n)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{4, 13, "let add = fn(a, b)"},
+		{1, 12, "(parameter) a of fn(a, b)"},
+		{2, 3, "let sum = (a + b)"},
+		{5, 1, "len(value)"},
+	}
+
+	for _, test := range tests {
+		var hover *Hover
+		c.call("textDocument/hover", position(test.line, test.character), &hover)
+		if hover == nil {
+			t.Errorf("no hover at %d:%d", test.line, test.character)
+			continue
+		}
+
+		if !strings.Contains(hover.Contents.Value, test.expected) {
+			t.Errorf("hover at %d:%d got %q, but want it to contain %q", test.line, test.character, hover.Contents.Value, test.expected)
+		}
+	}
+
+	var hover *Hover
+	c.call("textDocument/hover", position(4, 21), &hover)
+	if hover != nil {
+		t.Errorf("hover on an integer should be empty, got %+v", hover)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		expected        Range
+	}{
+		// add in `add(1, 2)` goes to the let
+		{4, 13, Range{Position{0, 4}, Position{0, 7}}},
+		// b in `a + b` goes to the parameter
+		{1, 16, Range{Position{0, 16}, Position{0, 17}}},
+		// sum at the end of the body goes to the inner let
+		{2, 2, Range{Position{1, 6}, Position{1, 9}}},
+		// total inside the array literal
+		{5, 6, Range{Position{4, 4}, Position{4, 9}}},
+	}
+
+	for _, test := range tests {
+		var location *Location
+		c.call("textDocument/definition", position(test.line, test.character), &location)
+		if location == nil {
+			t.Errorf("no definition found at %d:%d", test.line, test.character)
+			continue
+		}
+
+		if location.URI != testURI || location.Range != test.expected {
+			t.Errorf("definition at %d:%d got %+v, but want %+v", test.line, test.character, location.Range, test.expected)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}, &symbols)
+
+	if len(symbols) != 2 {
+		t.Fatalf("expected 2 symbols, got %+v", symbols)
+	}
+
+	add := symbols[0]
+	if add.Name != "add" || add.Kind != symbolFunction || add.Detail != "fn(a, b)" {
+		t.Errorf("unexpected symbol for add %+v", add)
+	}
+	if add.Range.Start != (Position{0, 0}) || add.Range.End != (Position{3, 1}) {
+		t.Errorf("add has wrong range %+v", add.Range)
+	}
+	if len(add.Children) != 1 || add.Children[0].Name != "sum" {
+		t.Errorf("add should contain sum, got %+v", add.Children)
+	}
+
+	if symbols[1].Name != "total" || symbols[1].Kind != symbolVariable {
+		t.Errorf("unexpected symbol for total %+v", symbols[1])
+	}
+}
+
+func TestImports(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("import \"lib/math.monkey\" as math;\nmath[\"double\"](2)\n")
+
+	var hover *Hover
+	c.call("textDocument/hover", position(1, 1), &hover)
+	if hover == nil || !strings.Contains(hover.Contents.Value, `import "lib/math.monkey" as math`) {
+		t.Errorf("hover on an imported module got %+v", hover)
+	}
+
+	var location *Location
+	c.call("textDocument/definition", position(1, 1), &location)
+	if location == nil || location.Range != (Range{Position{0, 28}, Position{0, 32}}) {
+		t.Errorf("definition of an imported module got %+v", location)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": testURI}}, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "math" || symbols[0].Kind != symbolModule {
+		t.Errorf("unexpected symbols for an import %+v", symbols)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open(program)
+
+	labels := func(line, character int) map[string]bool {
+		var items []CompletionItem
+		c.call("textDocument/completion", position(line, character), &items)
+		found := make(map[string]bool)
+		for _, item := range items {
+			found[item.Label] = true
+		}
+		return found
+	}
+
+	inside := labels(2, 2)
+	for _, name := range []string{"a", "b", "sum", "add", "total", "len", "let"} {
+		if !inside[name] {
+			t.Errorf("completion inside the function is missing %q", name)
+		}
+	}
+
+	outside := labels(5, 0)
+	if outside["sum"] || outside["a"] {
+		t.Errorf("locals of add should not be offered outside of it")
+	}
+	if !outside["add"] || !outside["put"] {
+		t.Errorf("completion at the top level is missing globals or builtins")
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+	c.open("let add=fn(a,b){a+b};\nadd(1,2)")
+
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"options":      map[string]any{"tabSize": 2, "insertSpaces": true},
+	}
+
+	var edits []TextEdit
+	c.call("textDocument/formatting", params, &edits)
+	if len(edits) != 1 {
+		t.Fatalf("expected one edit, got %+v", edits)
+	}
+
+	expected := "let add = fn(a, b) {\n  a + b;\n};\nadd(1, 2);\n"
+	if edits[0].NewText != expected {
+		t.Errorf("formatted text got %q, but want %q", edits[0].NewText, expected)
+	}
+	if edits[0].Range.End != (Position{1, 8}) {
+		t.Errorf("edit should cover the whole document, got %+v", edits[0].Range)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	rerr := c.call("textDocument/rename", position(0, 0), nil)
+	if rerr == nil || rerr.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", rerr)
+	}
+}
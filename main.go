@@ -2,17 +2,66 @@ package main
 
 import (
 	"fmt"
-	"interpreter/repl"
 	"os"
-	"os/user"
+	"strings"
 )
 
+const USAGE = `usage:
+  monkey [-path <dirs>] [-allow-read <dirs>] [-allow-write <dirs>]
+                                  start the interactive interpreter
+  monkey serve [-shared] [-path <dirs>] [-allow-read <dirs>] [-allow-write <dirs>] <addr>
+                                  serve REPL sessions on addr
+  monkey connect <addr>           attach to a served REPL session
+  monkey lsp                      run the language server on stdin and stdout
+  monkey run [-path <dirs>] [-allow-read <dirs>] [-allow-write <dirs>] [-profile <out.pprof>] [-folded <out.folded>] <file>
+                                  run a script, optionally profiling it
+  monkey test [-path <dirs>] [-allow-read <dirs>] [-allow-write <dirs>] [-cover] [-covertext <file>] [-coverhtml <file>] [-coverprofile <file>] [paths...]
+                                  run the *_test.monkey files under paths
+  monkey debug [-path <dirs>] [-allow-read <dirs>] [-allow-write <dirs>] <file>
+                                  run a script under the debugger
+  monkey dap [-listen <addr>]     run the debug adapter on stdin and stdout or on addr
+
+addresses are host:port for tcp or unix:/path/to/socket
+imports are searched next to the importing file and then in the -path
+directories, separated like PATH, or in $MONKEYPATH when -path is not given
+scripts can only use files below the -allow-read and -allow-write directories,
+read-only for the first, and none at all without them
+`
+
 func main() {
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	os.Exit(interactive(nil))
+}
+
+// run a subcommand and return the exit code of the process
+func runCommand(name string, args []string) int {
+	switch name {
+	case "serve":
+		return serve(args)
+	case "connect":
+		return connect(args)
+	case "lsp":
+		return languageServer(args)
+	case "run":
+		return run(args)
+	case "test":
+		return test(args)
+	case "debug":
+		return debug(args)
+	case "dap":
+		return debugAdapter(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(USAGE)
+		return 0
+	default:
+		// flags without a command are for the interactive interpreter
+		if strings.HasPrefix(name, "-") {
+			return interactive(append([]string{name}, args...))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", name, USAGE)
+		return 2
 	}
-	fmt.Printf("Hello %s, this is the stevie interpreter adventure, i'm tokenize the input\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
 }
@@ -0,0 +1,111 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Access is what scripts may do with the files below a root
+type Access int
+
+const (
+	ReadOnly Access = iota
+	ReadWrite
+)
+
+// Root is a directory scripts may use, along with everything below it
+type Root struct {
+	Path   string
+	Access Access
+}
+
+/**
+ * Files is the part of the file system open to the file builtins, a path is
+ * governed by the innermost root holding it, the last one given when two
+ * roots are the same directory, and the builtins cannot reach
+ * outside that root, not even through symbolic links, without Files on the
+ * host every file builtin fails
+ */
+type Files struct {
+	roots []Root
+}
+
+// NewFiles opens the directories of roots to scripts, relative ones are
+// taken from the working directory
+func NewFiles(roots ...Root) *Files {
+	f := &Files{}
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root.Path); err == nil {
+			root.Path = abs
+		}
+		f.roots = append(f.roots, Root{Path: filepath.Clean(root.Path), Access: root.Access})
+	}
+
+	return f
+}
+
+// AllowedRoots turns lists of directories separated like PATH into roots,
+// read lists the read-only directories and write the writable ones
+func AllowedRoots(read, write string) []Root {
+	roots := []Root{}
+	for _, path := range filepath.SplitList(read) {
+		roots = append(roots, Root{Path: path, Access: ReadOnly})
+	}
+	for _, path := range filepath.SplitList(write) {
+		roots = append(roots, Root{Path: path, Access: ReadWrite})
+	}
+
+	return roots
+}
+
+/**
+ * Open returns the root path lies in and the name of path inside it, write
+ * asks for a root scripts may change, the caller closes the root, paths
+ * which are not absolute are taken from the working directory
+ */
+func (f *Files) Open(path string, write bool) (*os.Root, string, error) {
+	if f == nil || len(f.roots) == 0 {
+		return nil, "", errors.New("file access is disabled")
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var within *Root
+	name := ""
+	for i, root := range f.roots {
+		rel, err := filepath.Rel(root.Path, abs)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if within == nil || len(root.Path) >= len(within.Path) {
+			within, name = &f.roots[i], rel
+		}
+	}
+
+	if within == nil {
+		return nil, "", fmt.Errorf("%s is outside the allowed directories", path)
+	}
+	if write && within.Access != ReadWrite {
+		return nil, "", fmt.Errorf("%s is in a read-only directory", path)
+	}
+
+	root, err := os.OpenRoot(within.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, name, nil
+}
+
+// the files open to the evaluation env belongs to, nil when there are none
+func (en *Environment) Files() *Files {
+	if en.host == nil {
+		return nil
+	}
+
+	return en.host.Files
+}
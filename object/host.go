@@ -0,0 +1,139 @@
+package object
+
+import (
+	"interpreter/ast"
+	"io"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+/**
+ * Host carries what the embedding program hands to a running script, an
+ * environment created by NewEnvironment has no host and falls back to the
+ * process defaults
+ */
+type Host struct {
+	// where builtins such as put write their output
+	Out io.Writer
+	// optional, follows the evaluation step by step
+	Tracer Tracer
+	// the imported files, created on the first import when not set
+	Modules *Modules
+	// the source of rand_int, rand_float and shuffle, randomly seeded on
+	// first use when not set, use NewRandom for a reproducible sequence
+	Random *rand.Rand
+	// the directories the file builtins may use, they all fail when not set
+	Files *Files
+	// the time now() gives, the system clock when not set, tests can stop
+	// it at a fixed time
+	Clock func() time.Time
+}
+
+// a generator giving the same numbers on every run for the same seed
+func NewRandom(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+/**
+ * Tracer is how debuggers and profilers follow an evaluation, it is told
+ * before every statement runs and around every call
+ */
+type Tracer interface {
+	// stmt is about to run in env, returning an error stops the evaluation with it
+	Statement(stmt ast.Statement, env *Environment) *Error
+	// fn is called from call, env is the environment of the caller
+	EnterCall(call *ast.CallExpression, fn Object, env *Environment)
+	// the call entered last returned result
+	ExitCall(call *ast.CallExpression, fn Object, result Object)
+}
+
+/**
+ * BranchTracer is a Tracer which also follows the way each if expression
+ * goes, consequence tells whether its consequence runs, otherwise the
+ * alternative runs or, without one, nothing
+ */
+type BranchTracer interface {
+	Tracer
+	Branch(ie *ast.IfExpression, consequence bool, env *Environment)
+}
+
+func (h *Host) Output() io.Writer {
+	if h == nil || h.Out == nil {
+		return os.Stdout
+	}
+
+	return h.Out
+}
+
+// the host of the evaluation this environment belongs to, may be nil
+func (en *Environment) Host() *Host {
+	return en.host
+}
+
+func (en *Environment) SetHost(h *Host) {
+	en.host = h
+}
+
+// SetTracer gives env a copy of its host with t as the tracer, so every
+// evaluation in env reports to t while the host it was given stays as it was
+func (en *Environment) SetTracer(t Tracer) {
+	traced := Host{}
+	if en.host != nil {
+		traced = *en.host
+	}
+	traced.Tracer = t
+	en.host = &traced
+}
+
+// nil when the host has no tracer
+func (h *Host) tracer() Tracer {
+	if h == nil {
+		return nil
+	}
+
+	return h.Tracer
+}
+
+// the tracer of the evaluation env belongs to, may be nil
+func (en *Environment) Tracer() Tracer {
+	return en.host.tracer()
+}
+
+// the random generator of the evaluation env belongs to, created on first use
+func (en *Environment) Random() *rand.Rand {
+	en.ensureHost()
+	if en.host.Random == nil {
+		en.host.Random = NewRandom(rand.Int64())
+	}
+
+	return en.host.Random
+}
+
+// the current time by the clock of the evaluation env belongs to
+func (en *Environment) Now() time.Time {
+	if en.host == nil || en.host.Clock == nil {
+		return time.Now()
+	}
+
+	return en.host.Clock()
+}
+
+// Seed restarts the random numbers of the evaluation env belongs to from seed
+func (en *Environment) Seed(seed int64) {
+	en.ensureHost()
+	en.host.Random = NewRandom(seed)
+}
+
+// give env and the environments around it a host unless it has one, so what
+// is created on it lasts beyond the current call
+func (en *Environment) ensureHost() {
+	if en.host != nil {
+		return
+	}
+
+	h := &Host{}
+	for scope := en; scope != nil && scope.host == nil; scope = scope.outer {
+		scope.host = h
+	}
+}
@@ -0,0 +1,118 @@
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the environment variable holding the default search paths for imports,
+// separated like PATH
+const MONKEYPATH = "MONKEYPATH"
+
+// Module is an imported file, only the names it binds with export let are
+// visible to the importer
+type Module struct {
+	Path    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	names := []string{}
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return "module " + m.Path + " (" + strings.Join(names, ", ") + ")"
+}
+
+/**
+ * Modules are the files imported during an evaluation, each one is
+ * evaluated once and its module shared by every import of it, Paths are
+ * searched in order for imports not found next to the importing file
+ */
+type Modules struct {
+	Paths []string
+
+	cache map[string]*Module
+	// files being evaluated, innermost last, importing one of them again
+	// would never finish
+	loading []string
+}
+
+func NewModules(paths ...string) *Modules {
+	return &Modules{Paths: paths, cache: make(map[string]*Module)}
+}
+
+// SearchPaths splits a list of directories separated like PATH, an empty
+// list gives the directories of $MONKEYPATH
+func SearchPaths(list string) []string {
+	if list == "" {
+		list = os.Getenv(MONKEYPATH)
+	}
+	if list == "" {
+		return nil
+	}
+
+	return filepath.SplitList(list)
+}
+
+// the module of the file at path if it was imported already
+func (m *Modules) Cached(path string) (*Module, bool) {
+	module, ok := m.cache[path]
+	return module, ok
+}
+
+/**
+ * Begin marks path as being evaluated, it returns the chain of imports
+ * leading back to path instead when path is already being evaluated
+ */
+func (m *Modules) Begin(path string) []string {
+	for i, loading := range m.loading {
+		if loading == path {
+			cycle := append([]string{}, m.loading[i:]...)
+			return append(cycle, path)
+		}
+	}
+
+	m.loading = append(m.loading, path)
+	return nil
+}
+
+// End marks the evaluation of the file begun last as finished, module is
+// nil when it failed
+func (m *Modules) End(path string, module *Module) {
+	m.loading = m.loading[:len(m.loading)-1]
+	if module != nil {
+		m.cache[path] = module
+	}
+}
+
+// the modules of the evaluation env belongs to, created on first use
+func (en *Environment) Modules() *Modules {
+	en.ensureHost()
+	if en.host.Modules == nil {
+		en.host.Modules = NewModules()
+	}
+
+	return en.host.Modules
+}
+
+// SetFile records that env is the top level of the script at path
+func (en *Environment) SetFile(path string) {
+	en.file = path
+}
+
+// path of the script env belongs to, empty when it does not come from a file
+func (en *Environment) File() string {
+	for scope := en; scope != nil; scope = scope.outer {
+		if scope.file != "" {
+			return scope.file
+		}
+	}
+
+	return ""
+}
@@ -2,11 +2,17 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"go/token"
 	"hash/fnv"
 	"interpreter/ast"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ObjectType string
@@ -23,6 +29,27 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// a float always shows a dot or an exponent, so it can not be taken for an
+// integer, very large and very small ones are written with an exponent
+func (f *Float) Inspect() string {
+	abs := math.Abs(f.Value)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) || math.IsInf(f.Value, 0) || math.IsNaN(f.Value) {
+		return strconv.FormatFloat(f.Value, 'g', -1, 64)
+	}
+
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -44,6 +71,9 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// where the error was raised when known, errors returned by builtins
+	// carry the position of their call
+	Line, Column int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -61,6 +91,30 @@ type String struct {
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
+// Time is an instant along with the time zone it is shown in
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+// Duration is the time between two instants, down to the nanosecond
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType { return DURATION_OBJ }
+func (d *Duration) Inspect() string  { return d.Value.String() }
+
+// Regex is a regular expression compiled once when it is created
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string  { return "/" + r.Value.String() + "/" }
+
 type Array struct {
 	Elements []Object
 }
@@ -81,6 +135,7 @@ func (ao *Array) Inspect() string {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -90,15 +145,68 @@ type HashPair struct {
 	Value Object
 }
 
+/**
+ * Hash keeps its pairs in the order their keys were first set, which is the
+ * order they are shown and walked in, an index from the hashed keys to
+ * their places keeps looking a key up as fast as in a map, the keys hashed
+ * alike are then compared one by one, so keys whose hashes collide stay
+ * apart, the zero value is an empty hash
+ */
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair
+	index map[HashKey][]int
+}
+
+// Set binds key to value, a key already in the hash keeps its place
+func (h *Hash) Set(key Hashable, value Object) {
+	hashed := key.HashKey()
+	if i, ok := h.find(hashed, key); ok {
+		h.pairs[i] = HashPair{Key: key, Value: value}
+		return
+	}
+
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+	h.index[hashed] = append(h.index[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	i, ok := h.find(key.HashKey(), key)
+	if !ok {
+		return HashPair{}, false
+	}
+
+	return h.pairs[i], true
+}
+
+// the place of key among the pairs, hashed is its hash key
+func (h *Hash) find(hashed HashKey, key Hashable) (int, bool) {
+	for _, i := range h.index[hashed] {
+		if sameKey(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// the pairs in the order of their keys, shared with the hash so they must
+// not be changed
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 	out.WriteString("{")
@@ -115,6 +223,52 @@ type HashKey struct {
 
 var hashMap map[HashKey]HashKey
 
+/**
+ * Key gives obj as a hash key, booleans, integers and strings are keys, and
+ * arrays whose elements are all keys, so [x, y] can stand for a pair of
+ * values
+ */
+func Key(obj Object) (Hashable, bool) {
+	if array, ok := obj.(*Array); ok {
+		for _, element := range array.Elements {
+			if _, ok := Key(element); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	key, ok := obj.(Hashable)
+	return key, ok
+}
+
+// whether a and b are the same key, equal hash keys do not tell it for sure
+func sameKey(a, b Object) bool {
+	switch a := a.(type) {
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i, element := range a.Elements {
+			if !sameKey(element, b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 	if b.Value {
@@ -137,9 +291,28 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// mixes the hash keys of the elements in turn, only arrays Key accepts are
+// used as keys
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, element := range ao.Elements {
+		key := HashKey{Type: element.Type()}
+		if element, ok := element.(Hashable); ok {
+			key = element.HashKey()
+		}
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf[:], key.Value)
+		h.Write(buf[:])
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	host  *Host
+	file  string
 }
 
 func (en *Environment) Get(name string) (Object, bool) {
@@ -156,7 +329,42 @@ func (en *Environment) Set(name string, val Object) Object {
 	return val
 }
 
-type BuiltinFunction func(args ...Object) Object
+// the enclosing environment, nil for the outermost one
+func (en *Environment) Outer() *Environment {
+	return en.outer
+}
+
+// names bound directly in this environment
+func (en *Environment) LocalNames() []string {
+	names := []string{}
+	for name := range en.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// every name visible from this environment, including the outer ones
+func (en *Environment) Names() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for scope := en; scope != nil; scope = scope.outer {
+		for name := range scope.store {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// env is the environment of the call site, which gives access to the host
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -173,38 +381,54 @@ type FunctionLiteral struct {
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   map[*ast.Identifier]ast.Expression
+	Variadic   bool
 	Body       *ast.BlockStatement
 	Env        *Environment
+	// name of the let binding the function was first bound to, errors about
+	// calls name it, empty for a function never bound
+	Name string
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
-	out.WriteString("fn")
-	out.WriteString("()")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n}")
+	out.WriteString("fn(")
+	out.WriteString(ast.ParameterList(f.Parameters, f.Defaults, f.Variadic))
+	out.WriteString(") { ")
 	out.WriteString(f.Body.String())
-	out.WriteString("\n}")
+	out.WriteString(" }")
 
 	return out.String()
 }
 
+// Arity returns how many arguments the function takes at least and at
+// most, max is -1 when it is variadic
+func (f *Function) Arity() (min, max int) {
+	for i, p := range f.Parameters {
+		if f.Variadic && i == len(f.Parameters)-1 {
+			return min, -1
+		}
+		if f.Defaults[p] == nil {
+			min++
+		}
+	}
+
+	return min, len(f.Parameters)
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.host = outer.host
 	return env
 }
 
 const (
 	STRING_OBJ   = "STRING"
 	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
 	BOOLEAN_OBJ  = "BOOLEAN"
 	NULL_OBJ     = "NULL"
 	RETURN_OBJ   = "RETURN_OBJ"
@@ -213,4 +437,8 @@ const (
 	BUILTIN_OBJ  = "BUILTIN"
 	ARRAY_OBJ    = "ARRAY"
 	HASH_OBJ     = "HASH"
+	MODULE_OBJ   = "MODULE"
+	REGEX_OBJ    = "REGEX"
+	TIME_OBJ     = "TIME"
+	DURATION_OBJ = "DURATION"
 )
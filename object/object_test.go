@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"interpreter/ast"
+	"strings"
+	"testing"
+)
 
 func TestStringHashedKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +24,181 @@ func TestStringHashedKey(t *testing.T) {
 		t.Errorf("hello1 and diff1 has different content but got same hash key")
 	}
 }
+
+// every collider hashes alike, so only comparing the keys tells them apart
+type collider struct{ name string }
+
+func (c *collider) Type() ObjectType { return "COLLIDER" }
+func (c *collider) Inspect() string  { return c.name }
+func (c *collider) HashKey() HashKey { return HashKey{Type: c.Type(), Value: 1} }
+
+func TestHashCollidingKeys(t *testing.T) {
+	a, b, c := &collider{"a"}, &collider{"b"}, &collider{"c"}
+	hash := &Hash{}
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(a, &Integer{Value: 3})
+
+	if hash.Len() != 2 {
+		t.Fatalf("hash has %d pairs, but want 2", hash.Len())
+	}
+	for key, want := range map[*collider]int64{a: 3, b: 2} {
+		pair, ok := hash.Get(key)
+		if !ok || pair.Value.(*Integer).Value != want {
+			t.Errorf("hash[%s] gave %v, %t, but want %d", key.name, pair.Value, ok, want)
+		}
+	}
+	if _, ok := hash.Get(c); ok {
+		t.Errorf("hash has c, which was never set")
+	}
+	if got := hash.Inspect(); got != "{a: 3, b: 2}" {
+		t.Errorf("hash is %s, but want {a: 3, b: 2}", got)
+	}
+}
+
+/**
+ * keys of the same type which really collide are hard to come by, so the
+ * hash is built with every key in one bucket, the keys have to be told
+ * apart by their values, and equal ones found although they are other
+ * objects
+ */
+func TestHashCollidingValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []Hashable
+		equal  Hashable
+		absent Hashable
+	}{
+		{
+			"strings",
+			[]Hashable{&String{Value: "a"}, &String{Value: "b"}},
+			&String{Value: "b"},
+			&String{Value: "c"},
+		},
+		{
+			"integers",
+			[]Hashable{&Integer{Value: 1}, &Integer{Value: 2}},
+			&Integer{Value: 2},
+			&Integer{Value: 3},
+		},
+		{
+			"arrays",
+			[]Hashable{
+				&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}},
+				&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "x"}}}}},
+			},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "x"}}}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "y"}}}}},
+		},
+	}
+
+	for _, test := range tests {
+		bucket := HashKey{Type: "BUCKET", Value: 1}
+		hash := &Hash{index: map[HashKey][]int{}}
+		for i, key := range test.keys {
+			hash.pairs = append(hash.pairs, HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+			hash.index[bucket] = append(hash.index[bucket], i)
+		}
+
+		pair, ok := hash.find(bucket, test.equal)
+		if !ok || pair != 1 {
+			t.Errorf("%s: found %s at %d, %t, but want 1", test.name, test.equal.Inspect(), pair, ok)
+		}
+		if i, ok := hash.find(bucket, test.absent); ok {
+			t.Errorf("%s: found %s at %d, but it is not in the hash", test.name, test.absent.Inspect(), i)
+		}
+		if i, ok := hash.find(bucket, test.keys[0]); !ok || i != 0 {
+			t.Errorf("%s: found %s at %d, %t, but want 0", test.name, test.keys[0].Inspect(), i, ok)
+		}
+	}
+}
+
+func TestArrayKeys(t *testing.T) {
+	pair := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	one, two := &Integer{Value: 1}, &String{Value: "2"}
+
+	if pair(one, two).HashKey() != pair(&Integer{Value: 1}, &String{Value: "2"}).HashKey() {
+		t.Errorf("equal arrays have different hash keys")
+	}
+	if pair(one, two).HashKey() == pair(two, one).HashKey() {
+		t.Errorf("arrays in a different order have the same hash key")
+	}
+	if _, ok := Key(pair(one, pair(two))); !ok {
+		t.Errorf("an array of keys is not a key")
+	}
+	if _, ok := Key(pair(one, pair(&Float{Value: 1}))); ok {
+		t.Errorf("an array holding a float is a key")
+	}
+	if _, ok := Key(&Float{Value: 1}); ok {
+		t.Errorf("a float is a key")
+	}
+}
+
+func TestFilesOpen(t *testing.T) {
+	files := NewFiles(
+		Root{Path: "/srv", Access: ReadOnly},
+		Root{Path: "/srv/out", Access: ReadWrite},
+		Root{Path: "/srv/out/frozen", Access: ReadOnly},
+		Root{Path: "/data", Access: ReadOnly},
+		Root{Path: "/data/", Access: ReadWrite},
+	)
+
+	tests := []struct {
+		path  string
+		write bool
+		err   string
+	}{
+		{"/srv/config.txt", false, ""},
+		{"/srv/config.txt", true, "/srv/config.txt is in a read-only directory"},
+		{"/srv/out/report.txt", true, ""},
+		{"/srv/out/frozen/report.txt", true, "/srv/out/frozen/report.txt is in a read-only directory"},
+		{"/srv/out/../config.txt", true, "/srv/out/../config.txt is in a read-only directory"},
+		{"/srvx/config.txt", false, "/srvx/config.txt is outside the allowed directories"},
+		{"/srv/../etc/passwd", false, "/srv/../etc/passwd is outside the allowed directories"},
+		{"/data/x", true, ""},
+	}
+
+	for _, test := range tests {
+		root, _, err := files.Open(test.path, test.write)
+		if root != nil {
+			root.Close()
+		}
+
+		switch {
+		case test.err == "" && err != nil && !strings.Contains(err.Error(), "no such file"):
+			t.Errorf("Open(%q, %t) failed with %q", test.path, test.write, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("Open(%q, %t) gave %v, but want %q", test.path, test.write, err, test.err)
+		}
+	}
+
+	var none *Files
+	if _, _, err := none.Open("/srv", false); err == nil || err.Error() != "file access is disabled" {
+		t.Errorf("no files should disable file access, got %v", err)
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Statement(stmt ast.Statement, env *Environment) *Error           { return nil }
+func (nopTracer) EnterCall(call *ast.CallExpression, fn Object, env *Environment) {}
+func (nopTracer) ExitCall(call *ast.CallExpression, fn Object, result Object)     {}
+
+func TestSetTracer(t *testing.T) {
+	env := NewEnvironment()
+	env.SetTracer(nopTracer{})
+	if env.Tracer() == nil {
+		t.Fatalf("environment without a host got no tracer")
+	}
+
+	host := &Host{Files: NewFiles()}
+	env = NewEnvironment()
+	env.SetHost(host)
+	env.SetTracer(nopTracer{})
+	if env.Tracer() == nil || env.Host().Files != host.Files {
+		t.Errorf("traced host does not keep what the host had")
+	}
+	if host.Tracer != nil {
+		t.Errorf("the host given to the environment got the tracer")
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"interpreter/lexer"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// seeds are the conformance programs and inputs from the parser tests
+func addSeeds(f *testing.F) {
+	programs, _ := filepath.Glob(filepath.Join("..", "conformance", "testdata", "*.monkey"))
+	for _, program := range programs {
+		if src, err := os.ReadFile(program); err == nil {
+			f.Add(string(src))
+		}
+	}
+
+	for _, input := range []string{
+		`let x = 5; let y = true; let foobar = y;`,
+		`return 5; return; return add(1, 2);`,
+		`a + b * c + d / e - f; 3 + 4; -5 * 5`,
+		`add(a * b[2], b[1], 2 * [1, 2][1])`,
+		`if (x < y) { x } else { y }`,
+		`fn(a, b) { let c = a + b; return c; }`,
+		`fn(a, b = 2, ...rest) { rest }`,
+		`{"one": 1, true: 2, 3: fn() { {} }}`,
+		`let = ; if (x { fn(,) }`,
+		`a b c`,
+		`connect(...args, host: "x", port: 1); [...a, ...b]; {...defaults, "k": v}`,
+		`import "lib/math.monkey" as math; export let m = import("a" + b)["c"]; import "x" y`,
+	} {
+		f.Add(input)
+	}
+}
+
+/**
+ * FuzzParseProgram parses arbitrary input, the parser must not panic and a
+ * program parsed without errors has to print as source which parses back
+ * to a program printing the same
+ */
+func FuzzParseProgram(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		printed := program.String()
+		reparsed := New(lexer.New(printed))
+		again := reparsed.ParseProgram()
+		if errors := reparsed.Errors(); len(errors) != 0 {
+			t.Fatalf("%q prints as %q, which does not parse: %v", input, printed, errors)
+		}
+		if again.String() != printed {
+			t.Fatalf("%q prints as %q, which parses back as %q", input, printed, again.String())
+		}
+	})
+}
@@ -13,6 +13,7 @@ type Parser struct {
 	curToken      token.Token
 	peekToken     token.Token
 	errors        []string
+	details       []Error
 	prefixParseFn map[token.TokenType]prefixParseFn
 	infixParseFn  map[token.TokenType]infixParseFn
 }
@@ -42,6 +43,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFn = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -52,6 +54,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 
 	p.infixParseFn = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -124,6 +127,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 		p.nextToken()
 	}
+	block.EndToken = p.curToken
 
 	return block
 }
@@ -149,7 +153,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectedPeek(token.LBRACE) {
 		return nil
@@ -160,35 +166,74 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+/**
+ * parameters are identifiers, those after the first one given a default
+ * with = need one as well, a last parameter written ...rest collects the
+ * arguments left over
+ */
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 	// check scenario of fn() -> no parameter
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.parseFunctionParameter(lit) {
+		return false
+	}
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if lit.Variadic {
+			rest := lit.Parameters[len(lit.Parameters)-1]
+			p.addError(p.curToken, fmt.Sprintf("rest parameter ...%s has to be the last one", rest.Value))
+			return false
+		}
+		if !p.parseFunctionParameter(lit) {
+			return false
+		}
+	}
+
+	return p.expectedPeek(token.RPAREN)
+}
+
+func (p *Parser) parseFunctionParameter(lit *ast.FunctionLiteral) bool {
+	variadic := false
+	if p.peekTokenIs(token.ELLIPSIS) {
 		p.nextToken()
-		ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		variadic = true
 	}
 
-	if !p.expectedPeek(token.RPAREN) {
-		return nil
+	if !p.expectedPeek(token.IDENT) {
+		return false
+	}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Parameters = append(lit.Parameters, ident)
+
+	switch {
+	case variadic:
+		lit.Variadic = true
+
+	case p.peekTokenIs(token.ASSIGN):
+		p.nextToken()
+		p.nextToken()
+		if lit.Defaults == nil {
+			lit.Defaults = make(map[*ast.Identifier]ast.Expression)
+		}
+		lit.Defaults[ident] = p.parseExpression(LOWEST)
+
+	case len(lit.Defaults) != 0:
+		p.addError(ident.Token, fmt.Sprintf("parameter %s needs a default value as the one before it has one", ident.Value))
+		return false
 	}
 
-	return identifiers
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseCallArguments()
 
 	return exp
 }
@@ -207,7 +252,8 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 // parsing call function like fn(3 + 1, 5 * 5) etc...
 // treat every argument as a Expression and recursively call
-// parseExpression to deal with parsing function calls
+// parseExpression to deal with parsing function calls, named arguments
+// like port: 80 come after the positional ones and each name only once
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -216,13 +262,32 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		return args
 	}
 
-	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
-
-	for p.peekTokenIs(token.COMMA) {
+	names := make(map[string]bool)
+	for {
 		p.nextToken()
+
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			arg := &ast.NamedArgument{Token: p.curToken, Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+			if names[arg.Name.Value] {
+				p.addError(arg.Token, fmt.Sprintf("argument %s given twice", arg.Name.Value))
+			}
+			names[arg.Name.Value] = true
+
+			p.nextToken()
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+			args = append(args, arg)
+		} else {
+			if len(names) != 0 {
+				p.addError(p.curToken, "positional argument after named arguments")
+			}
+			args = append(args, p.parseElement())
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
 	}
 
 	if !p.expectedPeek(token.RPAREN) {
@@ -232,6 +297,22 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+// an element of a list, which may be spread with ...
+func (p *Parser) parseElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	// defer untrace(trace("parseInfixExpression"))
 	expression := &ast.InfixExpression{
@@ -271,7 +352,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("error parsing token literal %q to integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("error parsing token literal %q to float", p.curToken.Literal)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -279,13 +374,30 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// Error is a parse error along with where in the source it was found
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// same errors as Errors, each with the position of the offending token
+func (p *Parser) ErrorDetails() []Error {
+	return p.details
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.details = append(p.details, Error{Message: msg, Line: tok.Line, Column: tok.Column})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) nextToken() {
@@ -311,9 +423,27 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// keep a failed let from turning into a non nil interface holding nil
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.EXPORT:
+		if stmt := p.parseExportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.IMPORT:
+		// import( starts an import expression
+		if !p.peekTokenIs(token.STRING) {
+			return p.parseExpressionStatement()
+		}
+		if stmt := p.parseImportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -391,6 +521,63 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// export let name = value
+func (p *Parser) parseExportStatement() *ast.LetStatement {
+	if !p.expectedPeek(token.LET) {
+		return nil
+	}
+
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+	stmt.Exported = true
+
+	return stmt
+}
+
+// import "path" as name, as is not a keyword so it stays usable as a name
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "as" {
+		p.addError(p.peekToken, fmt.Sprintf("expected as after the import path, got %s instead", p.peekToken.Literal))
+		return nil
+	}
+	p.nextToken()
+
+	if !p.expectedPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectedPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Path = p.parseExpression(LOWEST)
+
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -407,12 +594,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseElement())
 	}
 
 	if !p.expectedPeek(end) {
@@ -423,11 +610,25 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
-	hash := &ast.HashLiteral{}
+	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			spread := p.parseElement()
+			if spread == nil {
+				return nil
+			}
+			hash.Keys = append(hash.Keys, spread)
+
+			if !p.peekTokenIs(token.RBRACE) && !p.expectedPeek(token.COMMA) {
+				return nil
+			}
+			continue
+		}
+
 		key := p.parseExpression(LOWEST)
 
 		if !p.expectedPeek(token.COLON) {
@@ -436,6 +637,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		// check if peek token needed to skip
 		if !p.peekTokenIs(token.RBRACE) && !p.expectedPeek(token.COMMA) {
@@ -464,6 +666,7 @@ func (p *Parser) expectedPeek(t token.TokenType) bool {
 		p.nextToken()
 		return true
 	} else {
+		p.peekError(t)
 		return false
 	}
 }
@@ -471,7 +674,7 @@ func (p *Parser) expectedPeek(t token.TokenType) bool {
 // used by parsing unknown token
 func (p *Parser) noPrefixParseError(t token.TokenType) {
 	msg := fmt.Sprintf("no valid prefix parse function for %s", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 // define the precedence of operator
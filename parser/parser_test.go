@@ -128,6 +128,26 @@ func TestIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestFloatExpression(t *testing.T) {
+	p := New(lexer.New("2.5;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	lit, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.FloatLiteral, got %T", stmt.Expression)
+	}
+
+	if lit.Value != 2.5 || lit.TokenLiteral() != "2.5" {
+		t.Errorf("float literal is wrong got %v (%s) want 2.5", lit.Value, lit.TokenLiteral())
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	input := "true;"
 	l := lexer.New(input)
@@ -338,6 +358,67 @@ func TestParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults map[string]string
+		variadic         bool
+	}{
+		{"fn(a, b = 2) {}", []string{"a", "b"}, map[string]string{"b": "2"}, false},
+		{"fn(a = 1, b = a + 1) {}", []string{"a", "b"}, map[string]string{"a": "1", "b": "(a + 1)"}, false},
+		{"fn(...rest) {}", []string{"rest"}, map[string]string{}, true},
+		{"fn(a, b = [], ...rest) {}", []string{"a", "b", "rest"}, map[string]string{"b": "[]"}, true},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(test.expectedParams) {
+			t.Fatalf("length parameters wrong want %d, but got %d", len(test.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range test.expectedParams {
+			param := function.Parameters[i]
+			testLiteralExpression(t, param, ident)
+
+			def, ok := function.Defaults[param]
+			if want, wanted := test.expectedDefaults[ident]; wanted != ok || (ok && def.String() != want) {
+				t.Errorf("default of %s wrong, want %q, but got %v", ident, want, def)
+			}
+		}
+
+		if function.Variadic != test.variadic {
+			t.Errorf("variadic wrong for %q want %t, but got %t", test.input, test.variadic, function.Variadic)
+		}
+	}
+}
+
+func TestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "parameter b needs a default value as the one before it has one"},
+		{"fn(...rest, a) {}", "rest parameter ...rest has to be the last one"},
+		{"fn(...rest = 1) {}", "expected next token to be ), got = instead"},
+		{"fn(1) {}", "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("parsing %q want first error %q, but got %v", test.input, test.expected, errors)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 	l := lexer.New(input)
@@ -372,6 +453,114 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestNamedArgumentsAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`connect(host: "x", port: 1 + 1)`, `connect(host: "x", port: (1 + 1))`},
+		{`f(1, ...rest, name: a)`, `f(1, ...rest, name: a)`},
+		{`[...a, 1, ...b[0]]`, `[...a,1,...(b[0])]`},
+		{`{...defaults, "k": v, ...{"x": 1}}`, `{...defaults, "k": v, ...{"x": 1}}`},
+		{`{a: 1}`, `{a: 1}`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != test.expected {
+			t.Errorf("parsing %q got %q, but wanted %q", test.input, program.String(), test.expected)
+		}
+	}
+
+	program := New(lexer.New(`f(a, port: 80)`)).ParseProgram()
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	named, ok := call.Arguments[1].(*ast.NamedArgument)
+	if !ok {
+		t.Fatalf("second argument is not ast.NamedArgument, got %T", call.Arguments[1])
+	}
+	if named.Name.Value != "port" || !testIntegerLiteral(t, named.Value, 80) {
+		t.Errorf("named argument wrong, got %s", named)
+	}
+}
+
+func TestNamedArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(port: 1, 2)", "positional argument after named arguments"},
+		{"f(port: 1, ...rest)", "positional argument after named arguments"},
+		{"f(port: 1, port: 2)", "argument port given twice"},
+		{"let a = ...b", "no valid prefix parse function for ..."},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("parsing %q want first error %q, but got %v", test.input, test.expected, errors)
+		}
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math.monkey" as math;`, `import "lib/math.monkey" as math`},
+		{`let m = import("a" + ".monkey")`, `let m = import(("a" + ".monkey"))`},
+		{`import("a.monkey")["x"]`, `(import("a.monkey")["x"])`},
+		{`export let double = fn(x) { x * 2 };`, `export let double = fn(x) { (x * 2) }`},
+		{`let as = 1; as`, `let as = 1; as`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != test.expected {
+			t.Errorf("parsing %q got %q, but wanted %q", test.input, program.String(), test.expected)
+		}
+	}
+
+	program := New(lexer.New(`import "a.monkey" as a`)).ParseProgram()
+	stmt, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ImportStatement, got %T", program.Statements[0])
+	}
+	if stmt.Path.Value != "a.monkey" || stmt.Name.Value != "a" {
+		t.Errorf("import statement wrong, got %s", stmt)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`import "a.monkey"`, "expected as after the import path, got  instead"},
+		{`import "a.monkey" as`, "expected next token to be IDENT, got EOF instead"},
+		{`import "a.monkey" lib`, "expected as after the import path, got lib instead"},
+		{`export fn() {}`, "expected next token to be LET, got FUNCTION instead"},
+		{`import "a.monkey" as 1`, "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, test := range errors {
+		p := New(lexer.New(test.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("parsing %q want first error %q, but got %v", test.input, test.expected, errors)
+		}
+	}
+}
+
 func TestPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -490,7 +679,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + b * c + d / e- f",
 			"(((a + (b * c)) + (d / e)) - f)"},
 		{"3 + 4;-5 * 5",
-			"(3 + 4)((-5) * 5)"},
+			"(3 + 4); ((-5) * 5)"},
 		{"5 > 4 == 3 < 4",
 			"((5 > 4) == (3 < 4))"},
 		{"5 < 4 != 3 > 4",
@@ -542,6 +731,38 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionBodyString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(a, b) { let c = a + b; return c; }`,
+			"fn(a, b) { let c = (a + b); return c }"},
+		{`fn(x) { if (x > 1) { "big" } else { x } }`,
+			`fn(x) { if ((x > 1)) { "big" } else { x } }`},
+		{`fn() { {"k": [1, 2]} }`,
+			`fn() { {"k": [1,2]} }`},
+		{`fn(a, b = 1 + 1, ...rest) { rest }`,
+			`fn(a, b = (1 + 1), ...rest) { rest }`},
+	}
+
+	for _, test := range tests {
+		program := New(lexer.New(test.input)).ParseProgram()
+		actual := program.String()
+		if actual != test.expected {
+			t.Errorf("function printed wrong, got %q, but wanted %q", actual, test.expected)
+		}
+
+		// the printed function has to parse back to the same tree
+		reparsed := New(lexer.New(actual))
+		again := reparsed.ParseProgram()
+		checkParserErrors(t, reparsed)
+		if again.String() != actual {
+			t.Errorf("printed function does not parse back, got %q, but wanted %q", again.String(), actual)
+		}
+	}
+}
+
 func TestParsingArrayLiteral(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	l := lexer.New(input)
@@ -641,7 +862,7 @@ func TestParsingHashedLiteral(t *testing.T) {
 		if !ok {
 			t.Errorf("key is not a StringLiteral, got %T", key)
 		}
-		expectedValue := expected[literal.String()]
+		expectedValue := expected[literal.Value]
 		testIntegerLiteral(t, value, expectedValue)
 	}
 }
@@ -701,9 +922,9 @@ func TestParsingLiteralWithExpressions(t *testing.T) {
 			t.Errorf("key is not a StringLiteral, got %T", key)
 			continue
 		}
-		testFunc, ok := tests[literal.String()]
+		testFunc, ok := tests[literal.Value]
 		if !ok {
-			t.Errorf("No test function for key literal %q found", literal.String())
+			t.Errorf("No test function for key literal %q found", literal.Value)
 		}
 
 		// input the value of expression for make sure parsing expression inside hash is correctly
@@ -0,0 +1,182 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+/**
+ * WritePprof writes the profile as a gzipped profile.proto message, readable
+ * by go tool pprof, each stack is a sample holding its calls and time and
+ * each function and line pair a location
+ */
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strings := newStringTable()
+	profile := &protobuf{}
+
+	profile.message(1, valueType(strings, "calls", "count"))
+	profile.message(1, valueType(strings, "time", "nanoseconds"))
+
+	type locationKey struct {
+		fn   uint64
+		line int
+	}
+	locations := make(map[locationKey]uint64)
+	encodedLocations := []*protobuf{}
+
+	for _, s := range p.order {
+		ids := []uint64{}
+		for _, f := range s.stack {
+			key := locationKey{f.fn.id, f.line}
+			id, ok := locations[key]
+			if !ok {
+				id = uint64(len(locations) + 1)
+				locations[key] = id
+
+				line := &protobuf{}
+				line.uint64(1, f.fn.id)
+				line.int64(2, int64(f.line))
+
+				location := &protobuf{}
+				location.uint64(1, id)
+				location.message(4, line)
+				encodedLocations = append(encodedLocations, location)
+			}
+			ids = append(ids, id)
+		}
+
+		sample := &protobuf{}
+		sample.packedUint64s(1, ids)
+		sample.packedInt64s(2, []int64{s.calls, s.time.Nanoseconds()})
+		profile.message(2, sample)
+	}
+
+	for _, location := range encodedLocations {
+		profile.message(4, location)
+	}
+
+	functions := make([]*function, len(p.functions))
+	for _, fn := range p.functions {
+		functions[fn.id-1] = fn
+	}
+	for _, fn := range functions {
+		function := &protobuf{}
+		function.uint64(1, fn.id)
+		function.int64(2, strings.index(fn.name))
+		function.int64(3, strings.index(fn.name))
+		function.int64(4, strings.index(fn.file))
+		function.int64(5, int64(fn.start))
+		profile.message(5, function)
+	}
+
+	// the period type is needed before the string table is complete
+	period := valueType(strings, "time", "nanoseconds")
+
+	for _, s := range strings.values {
+		profile.string(6, s)
+	}
+	profile.int64(9, p.started.UnixNano())
+	profile.int64(10, p.Duration().Nanoseconds())
+	profile.message(11, period)
+	profile.int64(12, 1)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.bytes); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func valueType(strings *stringTable, kind, unit string) *protobuf {
+	vt := &protobuf{}
+	vt.int64(1, strings.index(kind))
+	vt.int64(2, strings.index(unit))
+	return vt
+}
+
+// stringTable collects the strings a profile refers to by index, the first is empty
+type stringTable struct {
+	values  []string
+	indices map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{values: []string{""}, indices: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indices[s]; ok {
+		return i
+	}
+
+	i := int64(len(t.values))
+	t.values = append(t.values, s)
+	t.indices[s] = i
+	return i
+}
+
+// protobuf encodes the few kinds of fields a profile is made of
+type protobuf struct {
+	bytes []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.bytes = append(b.bytes, byte(x)|0x80)
+		x >>= 7
+	}
+	b.bytes = append(b.bytes, byte(x))
+}
+
+func (b *protobuf) tag(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// zero values are the default and left out
+func (b *protobuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) length(field int, data []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.bytes = append(b.bytes, data...)
+}
+
+// strings of the string table are written even when empty, their position counts
+func (b *protobuf) string(field int, s string) {
+	b.length(field, []byte(s))
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.length(field, m.bytes)
+}
+
+func (b *protobuf) packedUint64s(field int, xs []uint64) {
+	packed := &protobuf{}
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.length(field, packed.bytes)
+}
+
+func (b *protobuf) packedInt64s(field int, xs []int64) {
+	packed := &protobuf{}
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.length(field, packed.bytes)
+}
@@ -0,0 +1,197 @@
+package profiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/object"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// name of the outermost frame, the script itself, pprof drops names in <>
+const PROGRAM = "main"
+
+// function is a Monkey function or builtin seen in a call
+type function struct {
+	id   uint64
+	name string
+	// the file defining a Monkey function, the profiled script for builtins
+	// and the program
+	file string
+	// line of the body of a Monkey function, 0 for builtins and the program
+	start int
+}
+
+// frame is an active call along with the line it is currently running
+type frame struct {
+	fn   *function
+	line int
+}
+
+// sample accumulates what happened while the stack looked the same
+type sample struct {
+	stack []frame // innermost first
+	calls int64
+	time  time.Duration
+}
+
+/**
+ * Profiler is an object.Tracer which attributes the time spent between two
+ * statements or calls to the stack of Monkey functions and lines that was
+ * active meanwhile, and counts how often each function is called
+ */
+type Profiler struct {
+	// the clock, replaceable for tests
+	Now func() time.Time
+
+	filename  string
+	started   time.Time
+	last      time.Time
+	stack     []frame // outermost first
+	functions map[string]*function
+	samples   map[string]*sample
+	order     []*sample
+}
+
+// filename is the script being profiled, it is recorded in the output
+func New(filename string) *Profiler {
+	p := &Profiler{
+		Now:       time.Now,
+		filename:  filename,
+		functions: make(map[string]*function),
+		samples:   make(map[string]*sample),
+	}
+	p.stack = []frame{{fn: p.function(PROGRAM, filename, 0)}}
+
+	return p
+}
+
+// Attach makes env and every evaluation in it report to the profiler
+func (p *Profiler) Attach(env *object.Environment) {
+	env.SetTracer(p)
+
+	p.started = p.Now()
+	p.last = p.started
+}
+
+func (p *Profiler) function(name, file string, start int) *function {
+	key := fmt.Sprintf("%s:%s:%d", name, file, start)
+	if fn, ok := p.functions[key]; ok {
+		return fn
+	}
+
+	fn := &function{id: uint64(len(p.functions) + 1), name: name, file: file, start: start}
+	p.functions[key] = fn
+	return fn
+}
+
+// the sample of the current stack
+func (p *Profiler) current() *sample {
+	var key strings.Builder
+	for _, f := range p.stack {
+		fmt.Fprintf(&key, "%d:%d;", f.fn.id, f.line)
+	}
+
+	s, ok := p.samples[key.String()]
+	if !ok {
+		stack := make([]frame, len(p.stack))
+		for i, f := range p.stack {
+			stack[len(p.stack)-1-i] = f
+		}
+
+		s = &sample{stack: stack}
+		p.samples[key.String()] = s
+		p.order = append(p.order, s)
+	}
+
+	return s
+}
+
+// charge the time since the last event to the current stack
+func (p *Profiler) tick() {
+	now := p.Now()
+	p.current().time += now.Sub(p.last)
+	p.last = now
+}
+
+func (p *Profiler) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	p.tick()
+	p.stack[len(p.stack)-1].line = ast.Start(stmt).Line
+	return nil
+}
+
+func (p *Profiler) EnterCall(call *ast.CallExpression, fn object.Object, env *object.Environment) {
+	p.tick()
+
+	file, start := p.filename, 0
+	if f, ok := fn.(*object.Function); ok && f.Body != nil {
+		start = f.Body.Token.Line
+		if f.Env != nil && f.Env.File() != "" {
+			file = f.Env.File()
+		}
+	}
+
+	p.stack = append(p.stack, frame{fn: p.function(call.Function.String(), file, start), line: start})
+	p.current().calls++
+}
+
+func (p *Profiler) ExitCall(call *ast.CallExpression, fn object.Object, result object.Object) {
+	p.tick()
+	if len(p.stack) > 1 {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// Stop charges the time up to now, call it once the evaluation is done
+func (p *Profiler) Stop() {
+	p.tick()
+}
+
+// Duration is the time profiled so far
+func (p *Profiler) Duration() time.Duration {
+	return p.last.Sub(p.started)
+}
+
+/**
+ * WriteFolded writes one line per stack, its frames from the outermost on
+ * as function:line separated by semicolons followed by the nanoseconds spent
+ * on it, the input flame graph tools expect
+ */
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	lines := []string{}
+	for _, s := range p.order {
+		if s.time <= 0 {
+			continue
+		}
+
+		frames := []string{}
+		for i := len(s.stack) - 1; i >= 0; i-- {
+			f := s.stack[i]
+			frames = append(frames, fmt.Sprintf("%s:%d", strings.ReplaceAll(f.fn.name, ";", ","), f.line))
+		}
+		lines = append(lines, fmt.Sprintf("%s %d\n", strings.Join(frames, ";"), s.time.Nanoseconds()))
+	}
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calls returns how often each function was called, by name
+func (p *Profiler) Calls() map[string]int64 {
+	calls := make(map[string]int64)
+	for _, s := range p.samples {
+		if s.calls > 0 {
+			calls[s.stack[0].fn.name] += s.calls
+		}
+	}
+
+	return calls
+}
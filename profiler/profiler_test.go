@@ -0,0 +1,248 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const script = `let double = fn(x) {
+  x * 2
+};
+let a = double(1);
+let b = double(a);
+len([a, b])
+`
+
+// profile script with a clock advancing one millisecond per reading
+func profile(t *testing.T) *Profiler {
+	t.Helper()
+
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse errors %v", p.Errors())
+	}
+
+	clock := time.Unix(0, 0)
+	prof := New("script.monkey")
+	prof.Now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	env := object.NewEnvironment()
+	prof.Attach(env)
+	evaluator.Eval(program, env)
+	prof.Stop()
+
+	return prof
+}
+
+func TestCalls(t *testing.T) {
+	calls := profile(t).Calls()
+
+	if calls["double"] != 2 || calls["len"] != 1 || len(calls) != 2 {
+		t.Errorf("unexpected call counts %v", calls)
+	}
+}
+
+func TestFolded(t *testing.T) {
+	prof := profile(t)
+
+	var out bytes.Buffer
+	if err := prof.WriteFolded(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// every event reads the clock once, so each stack gets a millisecond for
+	// each event ending a stretch spent on it
+	expected := `main:0 1000000
+main:1 1000000
+main:4 2000000
+main:4;double:1 1000000
+main:4;double:2 1000000
+main:5 2000000
+main:5;double:1 1000000
+main:5;double:2 1000000
+main:6 2000000
+main:6;len:0 1000000
+`
+	if out.String() != expected {
+		t.Errorf("folded stacks got\n%s\nbut want\n%s", out.String(), expected)
+	}
+
+	var total int64
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		_, count, _ := strings.Cut(line, " ")
+		n, err := strconv.ParseInt(count, 10, 64)
+		if err != nil {
+			t.Fatalf("bad count in %q", line)
+		}
+		total += n
+	}
+	if time.Duration(total) != prof.Duration() {
+		t.Errorf("stacks add up to %d, but the profile lasted %v", total, prof.Duration())
+	}
+}
+
+func TestPprof(t *testing.T) {
+	prof := profile(t)
+
+	var out bytes.Buffer
+	if err := prof.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := decode(t, data)
+
+	strs := []string{}
+	for _, s := range fields[6] {
+		strs = append(strs, string(s.([]byte)))
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("the string table has to start with an empty string, got %q", strs)
+	}
+	for _, expected := range []string{"calls", "time", "nanoseconds", "main", "double", "len", "script.monkey"} {
+		if !contains(strs, expected) {
+			t.Errorf("string table %q is missing %q", strs, expected)
+		}
+	}
+
+	if n := len(fields[2]); n != 10 {
+		t.Errorf("expected 10 samples, got %d", n)
+	}
+	if n := len(fields[5]); n != 3 {
+		t.Errorf("expected 3 functions, got %d", n)
+	}
+
+	var calls, nanos int64
+	for _, raw := range fields[2] {
+		sample := decode(t, raw.([]byte))
+		values := packed(sample[2][0].([]byte))
+		calls += int64(values[0])
+		nanos += int64(values[1])
+	}
+	if calls != 3 || time.Duration(nanos) != prof.Duration() {
+		t.Errorf("samples hold %d calls and %dns, but want 3 and %d", calls, nanos, prof.Duration())
+	}
+
+	if duration := fields[10][0].(uint64); time.Duration(duration) != prof.Duration() {
+		t.Errorf("duration got %d", duration)
+	}
+}
+
+// decode the fields of a protobuf message, varints become uint64 and
+// length delimited fields []byte
+func decode(t *testing.T, data []byte) map[int][]any {
+	t.Helper()
+
+	fields := make(map[int][]any)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			x, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[field] = append(fields[field], x)
+		case 2:
+			length, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[field] = append(fields[field], data[:length])
+			data = data[length:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+
+	return fields
+}
+
+func packed(data []byte) []uint64 {
+	values := []uint64{}
+	for len(data) > 0 {
+		x, n := binary.Uvarint(data)
+		data = data[n:]
+		values = append(values, x)
+	}
+
+	return values
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// functions defined in a module are located in its file, not in the script
+func TestPprofModuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.monkey")
+	if err := os.WriteFile(lib, []byte("export let triple = fn(x) { x * 3 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.monkey")
+	program := parser.New(lexer.New("import \"lib.monkey\" as lib;\nlib[\"triple\"](2)\n")).ParseProgram()
+
+	prof := New(main)
+	env := object.NewEnvironment()
+	env.SetFile(main)
+	prof.Attach(env)
+	evaluator.Eval(program, env)
+	prof.Stop()
+
+	var out bytes.Buffer
+	if err := prof.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := decode(t, data)
+	strs := []string{}
+	for _, s := range fields[6] {
+		strs = append(strs, string(s.([]byte)))
+	}
+
+	files := map[string]string{}
+	for _, raw := range fields[5] {
+		function := decode(t, raw.([]byte))
+		files[strs[function[2][0].(uint64)]] = strs[function[4][0].(uint64)]
+	}
+	if files["main"] != main || files[`(lib["triple"])`] != lib {
+		t.Errorf("functions are located in %v, but want main in %s and triple in %s", files, main, lib)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/object"
+	"interpreter/repl"
+	"os"
+)
+
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	shared := flags.Bool("shared", false, "evaluate every connection in one environment")
+	host := addHostFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	l, err := repl.Listen(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer l.Close()
+	fmt.Fprintf(os.Stderr, "serving REPL sessions on %s\n", l.Addr())
+
+	// sessions talk to clients, not to our terminal, so color depends on
+	// nothing we can see here and stays off
+	opts := repl.ServeOptions{Options: repl.DefaultOptions(os.Stdout)}
+	opts.Color = false
+	opts.ModulePaths = host.paths()
+	opts.Files = host.files()
+	if *shared {
+		opts.Env = object.NewEnvironment()
+	}
+
+	if err := repl.Serve(l, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func connect(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	if err := repl.Connect(args[0], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,117 @@
+package repl
+
+import (
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// completeFunc receives the text before the cursor and returns where the
+// word being completed starts along with every candidate replacing it
+type completeFunc func(head []rune) (start int, candidates []string)
+
+// completer offers names bound in the session, builtins and keywords, and
+// the string keys of a hash right after `h["`
+type completer struct {
+	env *object.Environment
+}
+
+func newCompleter(env *object.Environment) *completer {
+	return &completer{env: env}
+}
+
+func (c *completer) complete(head []rune) (int, []string) {
+	if start, candidates, ok := c.completeHashKey(head); ok {
+		return start, candidates
+	}
+
+	start := len(head)
+	for start > 0 && isIdentRune(head[start-1]) {
+		start--
+	}
+
+	word := string(head[start:])
+	if word == "" {
+		return start, nil
+	}
+
+	seen := make(map[string]bool)
+	candidates := []string{}
+	sources := [][]string{c.env.Names(), evaluator.BuiltinNames(), token.Keywords()}
+	for _, names := range sources {
+		for _, name := range names {
+			if strings.HasPrefix(name, word) && !seen[name] {
+				seen[name] = true
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	sort.Strings(candidates)
+
+	return start, candidates
+}
+
+// complete the key in `name["par` from the string keys of the hash bound to name
+func (c *completer) completeHashKey(head []rune) (int, []string, bool) {
+	quote := len(head) - 1
+	for quote >= 0 && head[quote] != '"' {
+		quote--
+	}
+	if quote < 1 || head[quote-1] != '[' {
+		return 0, nil, false
+	}
+
+	nameEnd := quote - 1
+	nameStart := nameEnd
+	for nameStart > 0 && isIdentRune(head[nameStart-1]) {
+		nameStart--
+	}
+	if nameStart == nameEnd {
+		return 0, nil, false
+	}
+
+	obj, ok := c.env.Get(string(head[nameStart:nameEnd]))
+	if !ok {
+		return 0, nil, false
+	}
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return 0, nil, false
+	}
+
+	partial := string(head[quote+1:])
+	candidates := []string{}
+	for _, pair := range hash.Pairs() {
+		key, ok := pair.Key.(*object.String)
+		if ok && strings.HasPrefix(key.Value, partial) {
+			candidates = append(candidates, key.Value+`"]`)
+		}
+	}
+	sort.Strings(candidates)
+
+	return quote + 1, candidates, true
+}
+
+func isIdentRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+// longest prefix shared by all candidates
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+
+	return prefix
+}
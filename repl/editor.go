@@ -0,0 +1,316 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// returned by readLine when the user abandons the current entry with Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// control keys handled by the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+/**
+ * lineEditor reads key presses from a terminal in raw mode and keeps the
+ * line being typed in buf with the cursor at pos, every change redraws the
+ * whole line so the terminal always mirrors the buffer
+ */
+type lineEditor struct {
+	r       *bufio.Reader
+	w       io.Writer
+	history *history
+	// optional, offers completions when tab is pressed
+	complete completeFunc
+	// optional, decorates the line with colors when it is drawn
+	highlight func(string) string
+
+	prompt string
+	buf    []rune
+	pos    int
+
+	// index into history while browsing with the arrow keys, and the line
+	// that was being typed before browsing started
+	histIdx int
+	pending []rune
+}
+
+func newLineEditor(r io.Reader, w io.Writer, h *history) *lineEditor {
+	return &lineEditor{r: bufio.NewReader(r), w: w, history: h}
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+	e.histIdx = len(e.history.entries)
+	e.pending = nil
+	e.refresh()
+
+	for {
+		r, _, err := e.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			io.WriteString(e.w, "\r\n")
+			return string(e.buf), nil
+
+		case keyCtrlC:
+			io.WriteString(e.w, "^C\r\n")
+			return "", errInterrupted
+
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.w, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+
+		case keyBackspace, keyCtrlH:
+			e.deleteBackward()
+
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			e.moveLeft()
+		case keyCtrlF:
+			e.moveRight()
+		case keyCtrlP:
+			e.historyPrev()
+		case keyCtrlN:
+			e.historyNext()
+
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = append(e.buf[:0], e.buf[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			e.deleteWord()
+
+		case keyCtrlL:
+			io.WriteString(e.w, "\x1b[H\x1b[2J")
+
+		case keyEscape:
+			e.readEscape()
+
+		case keyTab:
+			e.completeWord()
+
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(r)
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// handle the ANSI sequences sent by arrow, home, end and delete keys
+func (e *lineEditor) readEscape() {
+	next, _, err := e.r.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return
+	}
+
+	code, _, err := e.r.ReadRune()
+	if err != nil {
+		return
+	}
+
+	// sequences like ESC [ 3 ~ carry a numeric parameter before the final byte
+	if code >= '0' && code <= '9' {
+		param := code
+		for {
+			final, _, err := e.r.ReadRune()
+			if err != nil || final == '~' {
+				break
+			}
+			param = final
+		}
+
+		switch param {
+		case '1', '7':
+			e.pos = 0
+		case '4', '8':
+			e.pos = len(e.buf)
+		case '3':
+			e.deleteForward()
+		}
+		return
+	}
+
+	switch code {
+	case 'A':
+		e.historyPrev()
+	case 'B':
+		e.historyNext()
+	case 'C':
+		e.moveRight()
+	case 'D':
+		e.moveLeft()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.buf)
+	}
+}
+
+/**
+ * a single candidate replaces the word under the cursor, several candidates
+ * are narrowed down to their common prefix and listed below the line when
+ * there is nothing left to narrow
+ */
+func (e *lineEditor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start, candidates := e.complete(e.buf[:e.pos])
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := string(e.buf[start:e.pos])
+	if len(candidates) == 1 {
+		e.replace(start, candidates[0])
+		return
+	}
+
+	if prefix := commonPrefix(candidates); len(prefix) > len(word) {
+		e.replace(start, prefix)
+		return
+	}
+
+	io.WriteString(e.w, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+}
+
+// swap the runes between start and the cursor for text
+func (e *lineEditor) replace(start int, text string) {
+	rest := append([]rune(nil), e.buf[e.pos:]...)
+	e.buf = append(append(e.buf[:start], []rune(text)...), rest...)
+	e.pos = start + len([]rune(text))
+}
+
+func (e *lineEditor) insert(r rune) {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos++
+}
+
+func (e *lineEditor) deleteBackward() {
+	if e.pos == 0 {
+		return
+	}
+
+	e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+	e.pos--
+}
+
+func (e *lineEditor) deleteForward() {
+	if e.pos >= len(e.buf) {
+		return
+	}
+
+	e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+}
+
+// remove the word before the cursor along with any spaces trailing it
+func (e *lineEditor) deleteWord() {
+	start := e.pos
+	for start > 0 && e.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+
+	e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+	e.pos = start
+}
+
+func (e *lineEditor) moveLeft() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *lineEditor) moveRight() {
+	if e.pos < len(e.buf) {
+		e.pos++
+	}
+}
+
+func (e *lineEditor) historyPrev() {
+	if e.histIdx == 0 {
+		return
+	}
+
+	if e.histIdx == len(e.history.entries) {
+		e.pending = append([]rune(nil), e.buf...)
+	}
+
+	e.histIdx--
+	e.setLine([]rune(e.history.entries[e.histIdx]))
+}
+
+func (e *lineEditor) historyNext() {
+	if e.histIdx >= len(e.history.entries) {
+		return
+	}
+
+	e.histIdx++
+	if e.histIdx == len(e.history.entries) {
+		e.setLine(e.pending)
+		return
+	}
+
+	e.setLine([]rune(e.history.entries[e.histIdx]))
+}
+
+func (e *lineEditor) setLine(line []rune) {
+	e.buf = append(e.buf[:0], line...)
+	e.pos = len(e.buf)
+}
+
+// redraw the prompt and buffer, then put the cursor back where it belongs
+func (e *lineEditor) refresh() {
+	line := string(e.buf)
+	if e.highlight != nil {
+		line = e.highlight(line)
+	}
+
+	fmt.Fprintf(e.w, "\r%s%s\x1b[K", e.prompt, line)
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.w, "\x1b[%dD", back)
+	}
+}
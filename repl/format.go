@@ -0,0 +1,197 @@
+package repl
+
+import (
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+	"strconv"
+	"strings"
+)
+
+// ANSI colors used for highlighting input and results
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// collections whose one line form is wider than this are split over lines
+const PRETTY_WIDTH = 60
+
+// formatter renders evaluated objects for the REPL
+type formatter struct {
+	color  bool
+	pretty bool
+}
+
+func (f formatter) paint(color, s string) string {
+	if !f.color {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+// render the result of an evaluation, strings at the top level are printed
+// as they are while strings nested in collections are quoted
+func (f formatter) format(obj object.Object) string {
+	if !f.pretty {
+		if obj.Type() == object.ERROR_OBJ {
+			return f.paint(colorRed, obj.Inspect())
+		}
+		return f.paintObject(obj, obj.Inspect())
+	}
+
+	if str, ok := obj.(*object.String); ok {
+		return f.paint(colorGreen, str.Value)
+	}
+
+	return f.formatNested(obj, 0)
+}
+
+func (f formatter) formatNested(obj object.Object, depth int) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return f.paint(colorGreen, strconv.Quote(obj.Value))
+
+	case *object.Array:
+		items := []string{}
+		for _, el := range obj.Elements {
+			items = append(items, f.formatNested(el, depth+1))
+		}
+		return f.wrap("[", "]", items, depth)
+
+	case *object.Hash:
+		items := []string{}
+		for _, pair := range obj.Pairs() {
+			items = append(items, f.formatNested(pair.Key, depth+1)+": "+f.formatNested(pair.Value, depth+1))
+		}
+		return f.wrap("{", "}", items, depth)
+
+	case *object.Error:
+		return f.paint(colorRed, obj.Inspect())
+
+	default:
+		return f.paintObject(obj, obj.Inspect())
+	}
+}
+
+// keep short flat collections on one line, anything else gets one item per line
+func (f formatter) wrap(open, close string, items []string, depth int) string {
+	oneLine := open + strings.Join(items, ", ") + close
+	if visibleWidth(oneLine) <= PRETTY_WIDTH && !strings.Contains(oneLine, "\n") {
+		return oneLine
+	}
+
+	indent := strings.Repeat("  ", depth+1)
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for _, item := range items {
+		out.WriteString(indent + item + ",\n")
+	}
+	out.WriteString(strings.Repeat("  ", depth) + close)
+
+	return out.String()
+}
+
+func (f formatter) paintObject(obj object.Object, s string) string {
+	switch obj.Type() {
+	case object.INTEGER_OBJ, object.FLOAT_OBJ, object.TIME_OBJ, object.DURATION_OBJ:
+		return f.paint(colorCyan, s)
+	case object.STRING_OBJ, object.REGEX_OBJ:
+		return f.paint(colorGreen, s)
+	case object.BOOLEAN_OBJ:
+		return f.paint(colorYellow, s)
+	case object.NULL_OBJ:
+		return f.paint(colorGray, s)
+	case object.FUNCTION_OBJ, object.BUILTIN_OBJ:
+		return f.paint(colorMagenta, s)
+	default:
+		return s
+	}
+}
+
+// length of s as shown on screen, ignoring color escapes
+func visibleWidth(s string) int {
+	width := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			width++
+		}
+	}
+
+	return width
+}
+
+/**
+ * color source code as it is typed, keywords, builtins, numbers and strings
+ * each get their own color, everything else is left untouched so the output
+ * has the same visible width as the input
+ */
+func highlight(src string) string {
+	var out strings.Builder
+	runes := []rune(src)
+	builtins := map[string]bool{}
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j < len(runes) {
+				j++
+			}
+			out.WriteString(colorGreen + string(runes[i:j]) + colorReset)
+			i = j
+
+		case '0' <= r && r <= '9':
+			j := i
+			for j < len(runes) && ('0' <= runes[j] && runes[j] <= '9' || runes[j] == '.' && j+1 < len(runes) && '0' <= runes[j+1] && runes[j+1] <= '9') {
+				j++
+			}
+			out.WriteString(colorCyan + string(runes[i:j]) + colorReset)
+			i = j
+
+		case isIdentRune(r):
+			j := i
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			switch {
+			case token.CheckUpIdentifier(word) != token.IDENT:
+				out.WriteString(colorMagenta + word + colorReset)
+			case builtins[word]:
+				out.WriteString(colorBlue + word + colorReset)
+			default:
+				out.WriteString(word)
+			}
+			i = j
+
+		default:
+			out.WriteRune(r)
+			i++
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// maximum number of lines kept in memory and in the history file
+const HISTORY_LIMIT = 1000
+
+type history struct {
+	entries []string
+	path    string
+}
+
+func newHistory(path string) *history {
+	return &history{path: path}
+}
+
+// append a submitted line, blank lines and direct repeats are skipped
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > HISTORY_LIMIT {
+		h.entries = h.entries[len(h.entries)-HISTORY_LIMIT:]
+	}
+}
+
+// load previous sessions from the history file, a missing file is not an error
+func (h *history) load() error {
+	if h.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.add(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	var out strings.Builder
+	for _, entry := range h.entries {
+		out.WriteString(entry)
+		out.WriteString("\n")
+	}
+
+	return os.WriteFile(h.path, []byte(out.String()), 0600)
+}
+
+// location of the persistent history, $MONKEY_HISTORY wins over the home directory
+func historyPath() string {
+	if path := os.Getenv("MONKEY_HISTORY"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return home + string(os.PathSeparator) + ".monkey_history"
+}
@@ -0,0 +1,133 @@
+package repl
+
+import (
+	"bufio"
+	"interpreter/lexer"
+	"interpreter/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// tokens which can never end a complete statement, seeing one of these last
+// means the user is going to carry on typing on the next line
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.BANG:     true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.LET:      true,
+	token.FUNCTION: true,
+	token.IF:       true,
+	token.ELSE:     true,
+}
+
+/**
+ * report whether src still needs more lines before it can be parsed, that is
+ * when a string is left open, brackets are unbalanced or the last token is an
+ * operator, surplus closing brackets are left for the parser to complain about
+ */
+func isIncomplete(src string) bool {
+	if strings.Count(src, `"`)%2 != 0 {
+		return true
+	}
+
+	l := lexer.New(src)
+	depth := 0
+	var last token.Token
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	if depth > 0 {
+		return true
+	}
+
+	return depth == 0 && continuationTokens[last.Type]
+}
+
+// lineReader hands the REPL one physical line at a time
+type lineReader interface {
+	readLine(prompt string) (string, error)
+	addHistory(line string)
+	setHighlight(fn func(string) string)
+	close() error
+}
+
+// plainReader is used when the input is not a terminal, e.g. a pipe or a file
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (p *plainReader) readLine(prompt string) (string, error) {
+	io.WriteString(p.out, prompt)
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return p.scanner.Text(), nil
+}
+
+func (p *plainReader) addHistory(line string)              {}
+func (p *plainReader) setHighlight(fn func(string) string) {}
+func (p *plainReader) close() error                        { return nil }
+
+// terminalReader switches the terminal to raw mode while a line is edited
+type terminalReader struct {
+	fd      int
+	editor  *lineEditor
+	history *history
+}
+
+func (t *terminalReader) readLine(prompt string) (string, error) {
+	state, err := makeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore(t.fd, state)
+
+	return t.editor.readLine(prompt)
+}
+
+func (t *terminalReader) addHistory(line string)              { t.history.add(line) }
+func (t *terminalReader) setHighlight(fn func(string) string) { t.editor.highlight = fn }
+func (t *terminalReader) close() error                        { return t.history.save() }
+
+// use the line editor when both ends of the session are a terminal
+func newLineReader(in io.Reader, out io.Writer, complete completeFunc) lineReader {
+	inFile, ok := in.(*os.File)
+	if ok && isTerminal(int(inFile.Fd())) {
+		if outFile, ok := out.(*os.File); ok && isTerminal(int(outFile.Fd())) {
+			h := newHistory(historyPath())
+			h.load()
+			editor := newLineEditor(in, out, h)
+			editor.complete = complete
+			return &terminalReader{
+				fd:      int(inFile.Fd()),
+				editor:  editor,
+				history: h,
+			}
+		}
+	}
+
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
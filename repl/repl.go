@@ -1,17 +1,17 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
-	"interpreter/evaluator"
-	"interpreter/lexer"
 	"interpreter/object"
-	"interpreter/parser"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// shown while an unfinished entry is waiting for more lines
+const CONTINUE_PROMPT = ".. "
+
 const MONKEY_FACE = `       __,__
 .--. .-"
  "-. .--.
@@ -26,31 +26,83 @@ const MONKEY_FACE = `       __,__
 		 '-----'
 `
 
+// Options tune how a session presents itself
+type Options struct {
+	// highlight typed input and color results by type
+	Color bool
+	// split nested arrays and hashes over indented lines
+	Pretty bool
+	// directories searched for imports not found relative to the working directory
+	ModulePaths []string
+	// the directories the file builtins may use, none when nil
+	Files *object.Files
+}
+
+// color and pretty printing are on when out is a terminal and $NO_COLOR is
+// unset, imports are searched in $MONKEYPATH
+func DefaultOptions(out io.Writer) Options {
+	f, ok := out.(*os.File)
+	tty := ok && isTerminal(int(f.Fd()))
+
+	return Options{
+		Color:  tty && os.Getenv("NO_COLOR") == "",
+		Pretty: tty,
+
+		ModulePaths: object.SearchPaths(""),
+	}
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	StartWithOptions(in, out, DefaultOptions(out))
+}
+
+/**
+ * run a session reading from in, every prompt, result, error and anything
+ * the script prints goes to out
+ */
+func StartWithOptions(in io.Reader, out io.Writer, opts Options) {
+	run(in, out, newSession(object.NewEnvironment(), out, opts))
+}
+
+func run(in io.Reader, out io.Writer, s *session) {
+	reader := newLineReader(in, out, newCompleter(s.env).complete)
+	defer reader.close()
+	if s.format.color {
+		reader.setHighlight(highlight)
+	}
+
+	// lines of the entry being typed, kept until it is complete
+	var pending []string
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONTINUE_PROMPT
+		}
+
+		line, err := reader.readLine(prompt)
+		if err == errInterrupted {
+			pending = nil
+			continue
+		}
+		if err != nil {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+		reader.addHistory(line)
+		if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.command(strings.TrimSpace(line))
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		pending = append(pending, line)
+		source := strings.Join(pending, "\n")
+		if isIncomplete(source) {
+			continue
 		}
+		pending = nil
+
+		s.eval(source, true)
 	}
 }
 
@@ -0,0 +1,379 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"interpreter/object"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 5;", false},
+		{"", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n a + b\n}", false},
+		{"[1, 2,", true},
+		{"[1, 2, 3]", false},
+		{"add(1,", true},
+		{"5 +", true},
+		{"let a =", true},
+		{"if (x) { 1 } else", true},
+		{`"unterminated`, true},
+		{`"done"`, false},
+		{"}", false},
+		{"1 + 2)", false},
+	}
+
+	for _, test := range tests {
+		if got := isIncomplete(test.input); got != test.expected {
+			t.Errorf("isIncomplete(%q) got %t, but want %t", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string
+		history  []string
+		expected string
+	}{
+		{"plain typing", "let a = 5;\r", nil, "let a = 5;"},
+		{"backspace", "abd\x7fc\r", nil, "abc"},
+		{"insert after moving left", "ac\x1b[DB\r", nil, "aBc"},
+		{"home and end", "bc\x1b[HA\x1b[FD\r", nil, "AbcD"},
+		{"ctrl-a and ctrl-e", "bc\x01A\x05D\r", nil, "AbcD"},
+		{"delete key", "abc\x01\x1b[3~\r", nil, "bc"},
+		{"kill to end", "abcdef\x01\x06\x06\x0b\r", nil, "ab"},
+		{"kill to start", "abcdef\x02\x02\x15\r", nil, "ef"},
+		{"delete word", "let foo\x17bar\r", nil, "let bar"},
+		{"history up", "\x1b[A\r", []string{"first", "second"}, "second"},
+		{"history up twice", "\x1b[A\x1b[A\r", []string{"first", "second"}, "first"},
+		{"history back down", "typed\x1b[A\x1b[B\r", []string{"first"}, "typed"},
+		{"unicode", "\"héllo\"\x7f\x7f\"\r", nil, "\"héll\""},
+	}
+
+	for _, test := range tests {
+		h := newHistory("")
+		for _, entry := range test.history {
+			h.add(entry)
+		}
+
+		var out bytes.Buffer
+		e := newLineEditor(strings.NewReader(test.keys), &out, h)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if line != test.expected {
+			t.Errorf("%s: got line %q, but want %q", test.name, line, test.expected)
+		}
+	}
+}
+
+func TestLineEditorControl(t *testing.T) {
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader("let a\x03\x04"), &out, newHistory(""))
+
+	if _, err := e.readLine(PROMPT); !errors.Is(err, errInterrupted) {
+		t.Errorf("ctrl-c should abandon the line, got %v", err)
+	}
+
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("ctrl-d on an empty line should end input, got %v", err)
+	}
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := newHistory(path)
+	h.add("let a = 1;")
+	h.add("let a = 1;")
+	h.add("   ")
+	h.add("a + 1")
+	if err := h.save(); err != nil {
+		t.Fatalf("saving history failed: %v", err)
+	}
+
+	loaded := newHistory(path)
+	if err := loaded.load(); err != nil {
+		t.Fatalf("loading history failed: %v", err)
+	}
+
+	expected := []string{"let a = 1;", "a + 1"}
+	if strings.Join(loaded.entries, "|") != strings.Join(expected, "|") {
+		t.Errorf("history has wrong entries, got %q, want %q", loaded.entries, expected)
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("lengthy", &object.Integer{Value: 1})
+	env.Set("counter", &object.Integer{Value: 2})
+	env.Set("config", &object.Hash{})
+	hash := env.Set("conf", &object.Hash{}).(*object.Hash)
+	for _, k := range []string{"host", "hostname", "port"} {
+		key := &object.String{Value: k}
+		hash.Set(key, key)
+	}
+
+	tests := []struct {
+		input    string
+		start    int
+		expected []string
+	}{
+		{"le", 0, []string{"len", "lengthy", "let"}},
+		{"1 + cou", 4, []string{"counter"}},
+		{"pu", 0, []string{"push", "put"}},
+		{"re", 0, []string{"read_file", "reduce", "regex", "repeat", "replace", "replace_all", "rest", "return", "reverse"}},
+		{"let x = ", 8, nil},
+		{`conf["ho`, 6, []string{`host"]`, `hostname"]`}},
+		{`conf["`, 6, []string{`host"]`, `hostname"]`, `port"]`}},
+		{`counter["`, 0, []string{}},
+	}
+
+	c := newCompleter(env)
+	for _, test := range tests {
+		start, candidates := c.complete([]rune(test.input))
+		if len(test.expected) > 0 && start != test.start {
+			t.Errorf("complete(%q) starts at %d, but want %d", test.input, start, test.start)
+		}
+
+		if strings.Join(candidates, "|") != strings.Join(test.expected, "|") {
+			t.Errorf("complete(%q) got %q, but want %q", test.input, candidates, test.expected)
+		}
+	}
+}
+
+func TestLineEditorCompletion(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("counter", &object.Integer{Value: 1})
+	env.Set("count_all", &object.Integer{Value: 2})
+
+	tests := []struct {
+		keys     string
+		expected string
+		listing  string
+	}{
+		{"1 + cou\t\r", "1 + count", ""},
+		{"1 + count\t\r", "1 + count", "count_all  counter"},
+		{"cou\te\t + 1\r", "counter + 1", ""},
+		{"pus\t([], 1)\r", "push([], 1)", ""},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		e := newLineEditor(strings.NewReader(test.keys), &out, newHistory(""))
+		e.complete = newCompleter(env).complete
+
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if line != test.expected {
+			t.Errorf("keys %q produced %q, but want %q", test.keys, line, test.expected)
+		}
+
+		if test.listing != "" && !strings.Contains(out.String(), test.listing) {
+			t.Errorf("keys %q did not list the candidates %q", test.keys, test.listing)
+		}
+	}
+}
+
+func TestStartWritesToOut(t *testing.T) {
+	input := "let add = fn(a, b) {\n a + b\n};\nput(add(1, 2))\n)\n"
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{})
+
+	expected := ">> .. .. >> 3\nnull\n>> " + MONKEY_FACE
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("session output is wrong, got %q, want prefix %q", out.String(), expected)
+	}
+}
+
+func TestSessionFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "note.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := "read_file(\"" + path + "\")\n"
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{})
+	if !strings.Contains(out.String(), "file access is disabled") {
+		t.Errorf("a session without files should not read %s, got %q", path, out.String())
+	}
+
+	out.Reset()
+	files := object.NewFiles(object.Root{Path: dir, Access: object.ReadOnly})
+	StartWithOptions(strings.NewReader(input), &out, Options{Files: files})
+	if !strings.Contains(out.String(), ">> hello\n") {
+		t.Errorf("a session should read files its options allow, got %q", out.String())
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	nested := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1},
+		&object.String{Value: "two"},
+		&object.Array{Elements: []object.Object{&object.Boolean{Value: true}}},
+	}}
+	long := &object.Array{}
+	for i := 0; i < 25; i++ {
+		long.Elements = append(long.Elements, &object.Integer{Value: int64(i)})
+	}
+	wide := &object.Array{Elements: []object.Object{long}}
+
+	tests := []struct {
+		name     string
+		format   formatter
+		obj      object.Object
+		expected string
+	}{
+		{"plain", formatter{}, nested, "[1, two, [true]]"},
+		{"pretty short", formatter{pretty: true}, nested, `[1, "two", [true]]`},
+		{"pretty top level string", formatter{pretty: true}, &object.String{Value: "hi"}, "hi"},
+		{"colored integer", formatter{color: true}, &object.Integer{Value: 5}, colorCyan + "5" + colorReset},
+		{"colored error", formatter{color: true}, &object.Error{Message: "boom"}, colorRed + "ERROR: boom" + colorReset},
+		{"pretty wide", formatter{pretty: true}, wide, "[\n  [\n" + func() string {
+			var lines strings.Builder
+			for i := 0; i < 25; i++ {
+				lines.WriteString("    " + strconv.Itoa(i) + ",\n")
+			}
+			return lines.String()
+		}() + "  ],\n]"},
+	}
+
+	for _, test := range tests {
+		if got := test.format.format(test.obj); got != test.expected {
+			t.Errorf("%s: got %q, but want %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	src := `let s = len("hi") + 10;`
+	got := highlight(src)
+
+	if visibleWidth(got) != len(src) {
+		t.Errorf("highlighting changed the visible width, got %d, want %d", visibleWidth(got), len(src))
+	}
+
+	for _, part := range []string{colorMagenta + "let" + colorReset, colorBlue + "len" + colorReset, colorGreen + `"hi"` + colorReset, colorCyan + "10" + colorReset} {
+		if !strings.Contains(got, part) {
+			t.Errorf("highlight(%q) is missing %q, got %q", src, part, got)
+		}
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "session.monkey")
+	values := filepath.Join(dir, "values.monkey")
+
+	input := strings.Join([]string{
+		`let total = 40;`,
+		`let add = fn(a, b) {`,
+		`  let sum = a + b; if (sum > 100) { return 100 } else { sum }`,
+		`};`,
+		`let conf = {"name": "monkey", "ports": [80, 443]};`,
+		`let adder = fn(x) { fn(y) { x + y } };`,
+		`let addTwo = adder(2);`,
+		`let broken = missing;`,
+		`:save ` + script,
+		`:save -values ` + values,
+	}, "\n")
+
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{})
+	if !strings.Contains(out.String(), "saved 5 entries") {
+		t.Fatalf("entries were not saved, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "could not save: addTwo") {
+		t.Errorf("closure over a local scope should be skipped, got %q", out.String())
+	}
+
+	for _, path := range []string{script, values} {
+		var restored bytes.Buffer
+		input := ":restore " + path + "\nadd(total, 2)\nconf[\"ports\"][1]\nadd(total, 90)\n"
+		StartWithOptions(strings.NewReader(input), &restored, Options{})
+
+		for _, expected := range []string{"restored " + path, "42\n", "443\n", "100\n"} {
+			if !strings.Contains(restored.String(), expected) {
+				t.Errorf("restoring %s: output %q is missing %q", path, restored.String(), expected)
+			}
+		}
+	}
+}
+
+func TestRestoreFailures(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"let a = ;\n", "could not restore"},
+		{"let a = 1;\nlet b = missing;\n", "could not restore"},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, strconv.Itoa(i)+".monkey")
+		if err := os.WriteFile(path, []byte(test.source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		StartWithOptions(strings.NewReader(":restore "+path+"\n"), &out, Options{})
+		if !strings.Contains(out.String(), test.expected+" "+path) || strings.Contains(out.String(), "restored "+path) {
+			t.Errorf("restoring %q: got %q, but want only a failure", test.source, out.String())
+		}
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	env := object.NewEnvironment()
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		obj      object.Object
+		expected string
+		ok       bool
+	}{
+		{&object.Integer{Value: -5}, "-5", true},
+		{&object.String{Value: "hi there"}, `"hi there"`, true},
+		{&object.String{Value: `say "hi"`}, "", false},
+		{&object.Regex{Value: regexp.MustCompile(`\d+ (\w+)`)}, `regex("\d+ (\w+)")`, true},
+		{&object.Regex{Value: regexp.MustCompile(`"\w+"`)}, "", false},
+		{&object.Time{Value: time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)}, `parse_time("2024-05-01T12:00:00.0000005Z", "RFC3339Nano")`, true},
+		{&object.Time{Value: time.Date(2024, 5, 1, 12, 0, 0, 0, paris)}, `in_zone(parse_time("2024-05-01T12:00:00+02:00", "RFC3339Nano"), "Europe/Paris")`, true},
+		{&object.Duration{Value: 90 * time.Minute}, `duration("1h30m0s")`, true},
+		{&object.Array{Elements: []object.Object{&object.Boolean{Value: true}, &object.Integer{Value: 1}}}, "[true, 1]", true},
+		{&object.Null{}, "", false},
+		{&object.Builtin{}, "", false},
+	}
+
+	for _, test := range tests {
+		src, ok := serialize(test.obj, env)
+		if ok != test.ok || src != test.expected {
+			t.Errorf("serialize(%s) got (%q, %t), but want (%q, %t)", test.obj.Inspect(), src, ok, test.expected, test.ok)
+		}
+	}
+}
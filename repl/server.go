@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"errors"
+	"interpreter/object"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ServeOptions configure the sessions run by Serve
+type ServeOptions struct {
+	Options
+
+	// when set every connection evaluates in this environment, one at a time,
+	// otherwise each connection starts from a fresh one
+	Env *object.Environment
+}
+
+/**
+ * accept connections on l and run a REPL session on each until the client
+ * hangs up, Serve returns once l is closed
+ */
+func Serve(l net.Listener, opts ServeOptions) error {
+	var mu sync.Mutex
+
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		env := opts.Env
+		if env == nil {
+			env = object.NewEnvironment()
+		}
+
+		s := newSession(env, conn, opts.Options)
+		if opts.Env != nil {
+			s.mu = &mu
+		}
+
+		go func() {
+			defer conn.Close()
+			run(conn, conn, s)
+		}()
+	}
+}
+
+// split "unix:/path" and "tcp:host:port" addresses, a bare address is tcp
+func splitAddr(addr string) (string, string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+
+	return "tcp", strings.TrimPrefix(addr, "tcp:")
+}
+
+// Listen opens the listener for Serve from an address as accepted by Connect
+func Listen(addr string) (net.Listener, error) {
+	return net.Listen(splitAddr(addr))
+}
+
+/**
+ * attach to a session served at addr, in is forwarded to the server and what
+ * it answers is copied to out until the server closes the connection
+ */
+func Connect(addr string, in io.Reader, out io.Writer) error {
+	conn, err := net.Dial(splitAddr(addr))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, in)
+		// let the server see the end of input while its answers still arrive
+		if c, ok := conn.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+	}()
+
+	_, err = io.Copy(out, conn)
+	return err
+}
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"bytes"
+	"interpreter/object"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func startServer(t *testing.T, network, addr string, opts ServeOptions) string {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- Serve(l, opts) }()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned %v after the listener was closed", err)
+		}
+	})
+
+	if network == "unix" {
+		return "unix:" + l.Addr().String()
+	}
+	return l.Addr().String()
+}
+
+func runRemote(t *testing.T, addr, input string) string {
+	var out bytes.Buffer
+	if err := Connect(addr, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("connect to %s failed: %v", addr, err)
+	}
+
+	return out.String()
+}
+
+func TestServeIsolatesSessions(t *testing.T) {
+	addr := startServer(t, "tcp", "127.0.0.1:0", ServeOptions{})
+
+	first := runRemote(t, addr, "let a = 5;\nput(a * 2)\na\n")
+	expected := ">> >> 10\nnull\n>> 5\n>> "
+	if first != expected {
+		t.Errorf("first session got %q, but want %q", first, expected)
+	}
+
+	second := runRemote(t, addr, "a\n")
+	if !strings.Contains(second, "Identifier not found: a") {
+		t.Errorf("second session should not see the first one's bindings, got %q", second)
+	}
+}
+
+func TestServeSharedEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	addr := startServer(t, "tcp", "127.0.0.1:0", ServeOptions{Env: env})
+
+	runRemote(t, addr, "let greet = fn(name) { put(\"hi \" + name) };\n")
+	out := runRemote(t, addr, "greet(\"second\")\n")
+	if !strings.Contains(out, "hi second\n") {
+		t.Errorf("shared binding should print to the calling session, got %q", out)
+	}
+
+	if _, ok := env.Get("greet"); !ok {
+		t.Errorf("binding was not stored in the shared environment")
+	}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monkey.sock")
+	addr := startServer(t, "unix", path, ServeOptions{})
+
+	out := runRemote(t, addr, "let add = fn(a, b) {\n a + b\n};\nadd(1, 2)\n")
+	if !strings.Contains(out, ".. .. >> 3\n") {
+		t.Errorf("multi-line entry over a unix socket failed, got %q", out)
+	}
+}
+
+func TestSplitAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		address string
+	}{
+		{"localhost:4000", "tcp", "localhost:4000"},
+		{"tcp:127.0.0.1:4000", "tcp", "127.0.0.1:4000"},
+		{"unix:/tmp/monkey.sock", "unix", "/tmp/monkey.sock"},
+	}
+
+	for _, test := range tests {
+		network, address := splitAddr(test.addr)
+		if network != test.network || address != test.address {
+			t.Errorf("splitAddr(%q) got (%q, %q), but want (%q, %q)", test.addr, network, address, test.network, test.address)
+		}
+	}
+}
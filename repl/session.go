@@ -0,0 +1,261 @@
+package repl
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const HELP = `Commands:
+  :save <file>          write every entry of this session to file as a script
+  :save -values <file>  write the global bindings to file as let statements
+  :restore <file>       evaluate a saved file in this session
+  :help                 show this message
+`
+
+// session is the state of one REPL conversation
+type session struct {
+	env    *object.Environment
+	host   *object.Host
+	out    io.Writer
+	format formatter
+
+	// held while evaluating when env is shared with other sessions
+	mu *sync.Mutex
+
+	// source of every entry evaluated without errors, in order
+	entries []string
+}
+
+func newSession(env *object.Environment, out io.Writer, opts Options) *session {
+	return &session{
+		env:    env,
+		host:   &object.Host{Out: out, Modules: object.NewModules(opts.ModulePaths...), Files: opts.Files},
+		out:    out,
+		format: formatter{color: opts.Color, pretty: opts.Pretty},
+	}
+}
+
+// take the environment for this session and point it at our host, so put
+// and friends write to this session's output even when env is shared
+func (s *session) lock() {
+	if s.mu != nil {
+		s.mu.Lock()
+	}
+	s.env.SetHost(s.host)
+}
+
+func (s *session) unlock() {
+	if s.mu != nil {
+		s.mu.Unlock()
+	}
+}
+
+// evaluate one complete entry, echo prints its result
+func (s *session) eval(source string, echo bool) object.Object {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.out, p.Errors())
+		return nil
+	}
+
+	evaluated := s.evalProgram(program, source)
+	if echo && evaluated != nil {
+		io.WriteString(s.out, s.format.format(evaluated))
+		io.WriteString(s.out, "\n")
+	}
+
+	return evaluated
+}
+
+// evaluate the parsed source of an entry, which is kept unless it failed
+func (s *session) evalProgram(program *ast.Program, source string) object.Object {
+	s.lock()
+	evaluated := evaluator.Eval(program, s.env)
+	s.unlock()
+	if evaluated == nil || evaluated.Type() != object.ERROR_OBJ {
+		s.entries = append(s.entries, source)
+	}
+
+	return evaluated
+}
+
+// run a line starting with ':'
+func (s *session) command(line string) {
+	fields := strings.Fields(line)
+
+	var err error
+	switch {
+	case fields[0] == ":help":
+		io.WriteString(s.out, HELP)
+
+	case fields[0] == ":save" && len(fields) == 2:
+		err = s.save(fields[1])
+
+	case fields[0] == ":save" && len(fields) == 3 && fields[1] == "-values":
+		err = s.saveValues(fields[2])
+
+	case fields[0] == ":restore" && len(fields) == 2:
+		err = s.restore(fields[1])
+
+	default:
+		err = fmt.Errorf("unknown command %q, try :help", line)
+	}
+
+	if err != nil {
+		fmt.Fprintf(s.out, "%s\n", err)
+	}
+}
+
+// the entries are written one after another so the file runs as a script
+func (s *session) save(path string) error {
+	var out strings.Builder
+	for _, entry := range s.entries {
+		out.WriteString(entry)
+		if !strings.HasSuffix(strings.TrimSpace(entry), ";") {
+			out.WriteString(";")
+		}
+		out.WriteString("\n")
+	}
+
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.out, "saved %d entries to %s\n", len(s.entries), path)
+	return nil
+}
+
+// bindings without a literal form, like builtins, are reported and left out
+func (s *session) saveValues(path string) error {
+	var out strings.Builder
+	skipped := []string{}
+
+	s.lock()
+	defer s.unlock()
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		src, ok := serialize(value, s.env)
+		if !ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		fmt.Fprintf(&out, "let %s = %s;\n", name, src)
+	}
+
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.out, "saved values to %s\n", path)
+	if len(skipped) > 0 {
+		fmt.Fprintf(s.out, "could not save: %s\n", strings.Join(skipped, ", "))
+	}
+
+	return nil
+}
+
+func (s *session) restore(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("could not restore %s: %s", path, strings.Join(p.Errors(), ", "))
+	}
+
+	// the statements before a failing one have run and stay defined
+	if err, failed := s.evalProgram(program, string(src)).(*object.Error); failed {
+		return fmt.Errorf("could not restore %s: %s", path, err.Message)
+	}
+
+	fmt.Fprintf(s.out, "restored %s\n", path)
+	return nil
+}
+
+/**
+ * turn obj back into source which evaluates to an equal value, functions are
+ * only saved when they close over global, the environment the values are
+ * restored into, otherwise the captured locals would be lost
+ */
+func serialize(obj object.Object, global *object.Environment) (string, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.Boolean:
+		return obj.Inspect(), true
+
+	case *object.Float:
+		// infinities, NaN and exponents have no literal
+		src := obj.Inspect()
+		return src, !strings.ContainsAny(src, "eIN")
+
+	case *object.String:
+		// the lexer has no escapes so a quote can not be written inside a string
+		if strings.Contains(obj.Value, `"`) {
+			return "", false
+		}
+		return `"` + obj.Value + `"`, true
+
+	case *object.Time:
+		src := `parse_time("` + obj.Inspect() + `", "RFC3339Nano")`
+		// times read with an offset keep it, named zones have to be put back
+		if zone := obj.Value.Location().String(); zone != "UTC" && zone != "" {
+			src = `in_zone(` + src + `, "` + zone + `")`
+		}
+		return src, true
+
+	case *object.Duration:
+		return `duration("` + obj.Inspect() + `")`, true
+
+	case *object.Regex:
+		if strings.Contains(obj.Value.String(), `"`) {
+			return "", false
+		}
+		return `regex("` + obj.Value.String() + `")`, true
+
+	case *object.Array:
+		elements := []string{}
+		for _, el := range obj.Elements {
+			src, ok := serialize(el, global)
+			if !ok {
+				return "", false
+			}
+			elements = append(elements, src)
+		}
+		return "[" + strings.Join(elements, ", ") + "]", true
+
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range obj.Pairs() {
+			key, ok := serialize(pair.Key, global)
+			if !ok {
+				return "", false
+			}
+			value, ok := serialize(pair.Value, global)
+			if !ok {
+				return "", false
+			}
+			pairs = append(pairs, key+": "+value)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", true
+
+	case *object.Function:
+		if obj.Env != global {
+			return "", false
+		}
+		return obj.Inspect(), true
+
+	default:
+		return "", false
+	}
+}
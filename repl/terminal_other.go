@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package repl
+
+import "errors"
+
+type termState struct{}
+
+// raw mode is only supported on linux and darwin, other platforms fall back
+// to reading plain lines
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func restore(fd int, state *termState) error { return nil }
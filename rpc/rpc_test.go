@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	messages := []map[string]any{
+		{"id": 1, "method": "initialize"},
+		{"text": "héllo\r\n"},
+	}
+
+	for _, m := range messages {
+		if err := WriteMessage(&buf, m); err != nil {
+			t.Fatalf("writing message failed: %v", err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	expected := []string{`{"id":1,"method":"initialize"}`, `{"text":"héllo\r\n"}`}
+	for _, want := range expected {
+		body, err := ReadMessage(r)
+		if err != nil {
+			t.Fatalf("reading message failed: %v", err)
+		}
+
+		if string(body) != want {
+			t.Errorf("message body got %q, but want %q", body, want)
+		}
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	inputs := []string{
+		"Content-Length: abc\r\n\r\n{}",
+		"Content-Type: text\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+	}
+
+	for _, input := range inputs {
+		if _, err := ReadMessage(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("reading %q should fail", input)
+		}
+	}
+}
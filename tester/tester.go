@@ -0,0 +1,199 @@
+package tester
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/coverage"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// files holding tests end in this suffix
+const SUFFIX = "_test.monkey"
+
+// Options configure a test run
+type Options struct {
+	// receives the results and whatever the tests print
+	Out io.Writer
+	// when set, the statements and branches the tests run are recorded in it
+	Coverage *coverage.Coverage
+	// directories searched for imports not found next to the importing file
+	Paths []string
+	// the directories the tests may use through the file builtins, none when nil
+	Files *object.Files
+}
+
+/**
+ * Find returns the test files among paths, sorted, directories are searched
+ * recursively for files ending in SUFFIX while files are taken as they are
+ */
+func Find(paths []string) ([]string, error) {
+	files := []string{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, SUFFIX) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// test functions are the global functions whose name starts with PREFIX
+const PREFIX = "test_"
+
+// outcome of one test function, err is nil when it passed
+type result struct {
+	name string
+	err  *object.Error
+}
+
+/**
+ * Run evaluates each file in a fresh environment and then calls every test
+ * function it defines, in source order, reporting on opts.Out, a file fails
+ * when it does not parse or its top level ends in an error, a test when it
+ * returns an error, Run returns whether everything passed
+ */
+func Run(files []string, opts Options) bool {
+	passed, failed, broken := 0, 0, 0
+
+	for _, path := range files {
+		results, err := runFile(path, opts)
+		if err != "" {
+			fmt.Fprintf(opts.Out, "FAIL %s\n    %s\n", path, err)
+			broken++
+			continue
+		}
+
+		filePassed, fileFailed := 0, 0
+		for _, r := range results {
+			if r.err == nil {
+				fmt.Fprintf(opts.Out, "    PASS %s\n", r.name)
+				filePassed++
+			} else {
+				fmt.Fprintf(opts.Out, "    FAIL %s\n        %s\n", r.name, position(path, r.err))
+				fileFailed++
+			}
+		}
+		passed += filePassed
+		failed += fileFailed
+
+		switch {
+		case fileFailed > 0:
+			fmt.Fprintf(opts.Out, "FAIL %s (%d passed, %d failed)\n", path, filePassed, fileFailed)
+		case filePassed == 0:
+			fmt.Fprintf(opts.Out, "ok   %s (no tests)\n", path)
+		default:
+			fmt.Fprintf(opts.Out, "ok   %s (%d passed)\n", path, filePassed)
+		}
+	}
+
+	if failed > 0 || broken > 0 {
+		fmt.Fprintf(opts.Out, "FAIL: %d of %d tests failed, %d of %d files could not run\n", failed, passed+failed, broken, len(files))
+		return false
+	}
+
+	fmt.Fprintf(opts.Out, "PASS: %d tests in %d files\n", passed, len(files))
+	return true
+}
+
+// message of err prefixed with where it happened, as far as that is known
+func position(path string, err *object.Error) string {
+	if err.Line == 0 {
+		return fmt.Sprintf("%s: %s", path, err.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", path, err.Line, err.Column, err.Message)
+}
+
+// run one file and its tests, returns why the file failed as a whole or the
+// result of each test
+func runFile(path string, opts Options) ([]result, string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if details := p.ErrorDetails(); len(details) != 0 {
+		e := details[0]
+		return nil, fmt.Sprintf("%s:%d:%d: %s", path, e.Line, e.Column, e.Message)
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Out: opts.Out, Modules: object.NewModules(opts.Paths...), Files: opts.Files})
+	env.SetFile(path)
+	if opts.Coverage != nil {
+		opts.Coverage.Add(path, string(src), program)
+		opts.Coverage.Attach(env)
+	}
+
+	if err, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		return nil, position(path, err)
+	}
+
+	results := []result{}
+	for _, name := range testNames(program) {
+		fn, ok := env.Get(name)
+		if _, isFunction := fn.(*object.Function); !ok || !isFunction {
+			continue
+		}
+
+		r := result{name: name}
+		if min, _ := fn.(*object.Function).Arity(); min != 0 {
+			r.err = &object.Error{Message: fmt.Sprintf("test functions take no arguments, %s takes %d", name, min)}
+		} else if err, failed := evaluator.Apply(fn, nil, env).(*object.Error); failed {
+			r.err = err
+		}
+		results = append(results, r)
+	}
+
+	return results, ""
+}
+
+// names of the top level lets starting with PREFIX, in source order
+func testNames(program *ast.Program) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, PREFIX) || seen[let.Name.Value] {
+			continue
+		}
+
+		seen[let.Name.Value] = true
+		names = append(names, let.Name.Value)
+	}
+
+	return names
+}
@@ -0,0 +1,172 @@
+package tester
+
+import (
+	"bytes"
+	"interpreter/coverage"
+	"interpreter/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write files below a temporary directory and return it
+func tree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestFind(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"a_test.monkey":        "",
+		"helper.monkey":        "",
+		"nested/b_test.monkey": "",
+	})
+
+	files, err := Find([]string{dir, filepath.Join(dir, "helper.monkey")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a_test.monkey"),
+		filepath.Join(dir, "helper.monkey"),
+		filepath.Join(dir, "nested", "b_test.monkey"),
+	}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("found %v, but want %v", files, expected)
+	}
+
+	if _, err := Find([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("expected an error for a missing path")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"pass_test.monkey":   `put("hello"); 1 + 1`,
+		"fail_test.monkey":   `1 + true`,
+		"broken_test.monkey": `let = 1;`,
+	})
+	files, _ := Find([]string{dir})
+
+	var out bytes.Buffer
+	cov := coverage.New()
+	if Run(files, Options{Out: &out, Coverage: cov}) {
+		t.Errorf("the run should fail")
+	}
+
+	expected := []string{
+		"FAIL " + filepath.Join(dir, "broken_test.monkey") + "\n    " + filepath.Join(dir, "broken_test.monkey") + ":1:5: ",
+		"FAIL " + filepath.Join(dir, "fail_test.monkey") + "\n    " + filepath.Join(dir, "fail_test.monkey") + ": type mismatch: INTEGER + BOOLEAN\n",
+		"hello\nok   " + filepath.Join(dir, "pass_test.monkey") + " (no tests)\n",
+		"FAIL: 0 of 0 tests failed, 2 of 3 files could not run\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("output is missing %q\n%s", e, out.String())
+		}
+	}
+
+	// files which do not parse have nothing to cover
+	if n := len(cov.Files()); n != 2 {
+		t.Errorf("expected coverage of 2 files, got %d", n)
+	}
+}
+
+func TestRunTestFunctions(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"math_test.monkey": `let double = fn(x) { x * 2 };
+let test_double = fn() {
+  assert_eq(double(2), 4);
+};
+let test_broken = fn() {
+  assert_eq(double(2), 5, "doubling");
+};
+let test_errors = fn() {
+  assert_error(fn() { double(true) }, "type mismatch");
+};
+let test_params = fn(x) { x };
+let test_value = 1;
+`,
+		"other_test.monkey": `let test_ok = fn() { assert(true) };`,
+	})
+	files, _ := Find([]string{dir})
+
+	var out bytes.Buffer
+	if Run(files, Options{Out: &out}) {
+		t.Errorf("the run should fail")
+	}
+
+	math := filepath.Join(dir, "math_test.monkey")
+	expected := "    PASS test_double\n" +
+		"    FAIL test_broken\n" +
+		"        " + math + ":6:3: doubling: assert_eq failed: got 4, but want 5\n" +
+		"    PASS test_errors\n" +
+		"    FAIL test_params\n" +
+		"        " + math + ": test functions take no arguments, test_params takes 1\n" +
+		"FAIL " + math + " (2 passed, 2 failed)\n" +
+		"    PASS test_ok\n" +
+		"ok   " + filepath.Join(dir, "other_test.monkey") + " (1 passed)\n" +
+		"FAIL: 2 of 5 tests failed, 0 of 2 files could not run\n"
+
+	if out.String() != expected {
+		t.Errorf("output got\n%s\nbut want\n%s", out.String(), expected)
+	}
+
+	var passing bytes.Buffer
+	if !Run(files[1:], Options{Out: &passing}) {
+		t.Errorf("the run should pass")
+	}
+	if !strings.HasSuffix(passing.String(), "PASS: 1 tests in 1 files\n") {
+		t.Errorf("unexpected summary %q", passing.String())
+	}
+}
+
+func TestRunImports(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"pkg/math.monkey":      `export let double = fn(x) { x * 2 };`,
+		"pkg/math_test.monkey": `import "math.monkey" as math; let test_double = fn() { assert_eq(math["double"](2), 4) };`,
+		"lib/util.monkey":      `export let one = 1;`,
+		"util_test.monkey":     `import "util.monkey" as util; let test_one = fn() { assert_eq(util["one"], 1) };`,
+	})
+	files, _ := Find([]string{dir})
+
+	var out bytes.Buffer
+	if !Run(files, Options{Out: &out, Paths: []string{filepath.Join(dir, "lib")}}) {
+		t.Errorf("imports next to the test file and in the search paths should work, got\n%s", out.String())
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := tree(t, map[string]string{"data/greeting.txt": "hello"})
+	data := filepath.Join(dir, "data")
+	test := filepath.Join(dir, "files_test.monkey")
+	src := `let test_read = fn() { assert_eq(read_file("` + data + `/greeting.txt"), "hello") };`
+	if err := os.WriteFile(test, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if Run([]string{test}, Options{Out: &out}) {
+		t.Errorf("reading files should fail without Files, got\n%s", out.String())
+	}
+
+	out.Reset()
+	files := object.NewFiles(object.Root{Path: data, Access: object.ReadOnly})
+	if !Run([]string{test}, Options{Out: &out, Files: files}) {
+		t.Errorf("reading files below an allowed root should work, got\n%s", out.String())
+	}
+}
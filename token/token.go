@@ -1,12 +1,17 @@
 package token
 
+import "sort"
+
 // record the token type
 type TokenType string
 
-// a token has its own type and its literal value
+// a token has its own type and its literal value, Line and Column locate
+// its first character in the source, both counted from 1
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 const (
@@ -16,6 +21,7 @@ const (
 	// identifier & literal
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	ASSIGN   = "="
@@ -31,6 +37,7 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -47,6 +54,8 @@ const (
 	IF     = "IF"
 	ELSE   = "ELSE"
 	RETURN = "RETURN"
+	IMPORT = "IMPORT"
+	EXPORT = "EXPORT"
 
 	EQ     = "=="
 	NOT_EQ = "!="
@@ -60,6 +69,8 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"import": IMPORT,
+	"export": EXPORT,
 }
 
 /**
@@ -72,3 +83,14 @@ func CheckUpIdentifier(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// every reserved word of the language in alphabetical order
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return words
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/coverage"
+	"interpreter/dap"
+	"interpreter/debugger"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/lsp"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/profiler"
+	"interpreter/repl"
+	"interpreter/tester"
+	"io"
+	"os"
+	"os/user"
+)
+
+// the interactive interpreter on stdin and stdout
+func interactive(args []string) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	host := addHostFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Hello %s, this is the stevie interpreter adventure, i'm tokenize the input\n", user.Username)
+	fmt.Printf("Feel free to type in commands\n")
+
+	opts := repl.DefaultOptions(os.Stdout)
+	opts.ModulePaths = host.paths()
+	opts.Files = host.files()
+	repl.StartWithOptions(os.Stdin, os.Stdout, opts)
+	return 0
+}
+
+func languageServer(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	profile := flags.String("profile", "", "write a pprof profile of the script to this file")
+	folded := flags.String("folded", "", "write the profile as folded stacks for flame graphs to this file")
+	host := addHostFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	program, _, ok := parseFile(path)
+	if !ok {
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(host.host(os.Stdout))
+	env.SetFile(path)
+
+	var prof *profiler.Profiler
+	if *profile != "" || *folded != "" {
+		prof = profiler.New(path)
+		prof.Attach(env)
+	}
+
+	result := evaluator.Eval(program, env)
+
+	code := 0
+	if err, failed := result.(*object.Error); failed {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		code = 1
+	}
+
+	if prof != nil {
+		prof.Stop()
+		if err := writeFile(*profile, prof.WritePprof); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+		if err := writeFile(*folded, prof.WriteFolded); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func test(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	cover := flags.Bool("cover", false, "print the statement and branch coverage of each file")
+	text := flags.String("covertext", "", "write the coverage of every line as text to this file")
+	html := flags.String("coverhtml", "", "write an HTML coverage report to this file")
+	lcov := flags.String("coverprofile", "", "write the coverage as an LCOV tracefile to this file")
+	host := addHostFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := tester.Find(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test files found")
+		return 1
+	}
+
+	opts := tester.Options{
+		Out:   os.Stdout,
+		Paths: host.paths(),
+		Files: host.files(),
+	}
+	if *cover || *text != "" || *html != "" || *lcov != "" {
+		opts.Coverage = coverage.New()
+	}
+
+	code := 0
+	if !tester.Run(files, opts) {
+		code = 1
+	}
+
+	if cov := opts.Coverage; cov != nil {
+		for _, f := range cov.Files() {
+			fmt.Println(f.Summary())
+		}
+
+		reports := []struct {
+			path  string
+			write func(io.Writer) error
+		}{{*text, cov.WriteText}, {*html, cov.WriteHTML}, {*lcov, cov.WriteLCOV}}
+
+		for _, report := range reports {
+			if err := writeFile(report.path, report.write); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+		}
+	}
+
+	return code
+}
+
+// create path and fill it with write, nothing happens for an empty path
+func writeFile(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func debugAdapter(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	listen := flags.String("listen", "", "serve debug sessions on this address instead of stdin and stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	var err error
+	if *listen == "" {
+		err = dap.Serve(os.Stdin, os.Stdout)
+	} else {
+		l, lerr := repl.Listen(*listen)
+		if lerr != nil {
+			fmt.Fprintln(os.Stderr, lerr)
+			return 1
+		}
+		defer l.Close()
+		fmt.Fprintf(os.Stderr, "serving debug sessions on %s\n", l.Addr())
+
+		err = dap.ServeListener(l)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func debug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	host := addHostFlags(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, USAGE)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	program, source, ok := parseFile(path)
+	if !ok {
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(host.host(os.Stdout))
+	env.SetFile(path)
+
+	result := debugger.NewTerminal(source, os.Stdin, os.Stdout).Run(program, env)
+	if _, failed := result.(*object.Error); failed {
+		return 1
+	}
+
+	return 0
+}
+
+// read and parse a script, parse errors are reported on stderr
+func parseFile(path string) (*ast.Program, string, bool) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, "", false
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if details := p.ErrorDetails(); len(details) != 0 {
+		for _, e := range details {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, e.Line, e.Column, e.Message)
+		}
+		return nil, "", false
+	}
+
+	return program, string(src), true
+}